@@ -0,0 +1,297 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adaptive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	kutil "kmodules.xyz/client-go"
+	cu "kmodules.xyz/client-go/client"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Client reads and writes objects using their hub Go types (eg, batch/v1 CronJob), while
+// talking to the api server using the best version served by the cluster. Objects are
+// converted using the conversions registered in the scheme of the underlying client.
+type Client struct {
+	c        client.Client
+	versions map[schema.GroupVersionKind][]schema.GroupVersionKind
+
+	mu       sync.Mutex
+	selected map[schema.GroupVersionKind]schema.GroupVersionKind
+}
+
+// New returns a Client for the kinds listed in DefaultVersions. The scheme of c must
+// include the conversions registered by AddToScheme.
+func New(c client.Client) *Client {
+	return NewWithVersions(c, DefaultVersions)
+}
+
+// NewWithVersions returns a Client for the given set of hub kinds and their candidate
+// versions, listed in the order of preference.
+func NewWithVersions(c client.Client, versions map[schema.GroupVersionKind][]schema.GroupVersionKind) *Client {
+	return &Client{
+		c:        c,
+		versions: versions,
+		selected: map[schema.GroupVersionKind]schema.GroupVersionKind{},
+	}
+}
+
+// Reset forgets the selected versions, so that the served versions are detected again
+// on next use. Call this after the cluster has been upgraded.
+func (a *Client) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.selected = map[schema.GroupVersionKind]schema.GroupVersionKind{}
+}
+
+// ServedVersion returns the version used to talk to the api server for the given hub kind.
+func (a *Client) ServedVersion(hub schema.GroupVersionKind) (schema.GroupVersionKind, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if gvk, ok := a.selected[hub]; ok {
+		return gvk, nil
+	}
+
+	candidates, ok := a.versions[hub]
+	if !ok {
+		return schema.GroupVersionKind{}, fmt.Errorf("no versions registered for hub kind %v", hub)
+	}
+	for _, gvk := range candidates {
+		_, err := a.c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return schema.GroupVersionKind{}, err
+		}
+		klog.V(5).Infof("using %v for %v", gvk, hub)
+		a.selected[hub] = gvk
+		return gvk, nil
+	}
+	return schema.GroupVersionKind{}, &meta.NoKindMatchError{
+		GroupKind:        hub.GroupKind(),
+		SearchedVersions: versionsOf(candidates),
+	}
+}
+
+func versionsOf(gvks []schema.GroupVersionKind) []string {
+	versions := make([]string, 0, len(gvks))
+	for _, gvk := range gvks {
+		versions = append(versions, gvk.Version)
+	}
+	return versions
+}
+
+// served returns an empty object of the served version of obj. If the served version is
+// the same as the hub version, obj is returned.
+func (a *Client) served(obj runtime.Object) (runtime.Object, bool, error) {
+	hub, err := apiutil.GVKForObject(obj, a.c.Scheme())
+	if err != nil {
+		return nil, false, err
+	}
+	gvk, err := a.ServedVersion(hub)
+	if err != nil {
+		return nil, false, err
+	}
+	if gvk == hub {
+		return obj, false, nil
+	}
+	out, err := a.c.Scheme().New(gvk)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+func (a *Client) convert(in, out runtime.Object) error {
+	gvk := out.GetObjectKind().GroupVersionKind()
+	if err := a.c.Scheme().Convert(in, out, nil); err != nil {
+		return errors.Wrapf(err, "failed to convert %T to %T", in, out)
+	}
+	if !gvk.Empty() {
+		out.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+	return nil
+}
+
+// Get retrieves an obj for the given object key from the api server and stores it in
+// obj, which must be of a hub type.
+func (a *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	served, converted, err := a.served(obj)
+	if err != nil {
+		return err
+	}
+	if !converted {
+		return a.c.Get(ctx, key, obj, opts...)
+	}
+	if err := a.c.Get(ctx, key, served.(client.Object), opts...); err != nil {
+		return err
+	}
+	return a.convert(served, obj)
+}
+
+// List retrieves the list of objects for the given options and stores it in list,
+// which must be the list type of a hub type.
+func (a *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listGVK, err := apiutil.GVKForObject(list, a.c.Scheme())
+	if err != nil {
+		return err
+	}
+	hub := listGVK.GroupVersion().WithKind(strings.TrimSuffix(listGVK.Kind, "List"))
+	gvk, err := a.ServedVersion(hub)
+	if err != nil {
+		return err
+	}
+	if gvk == hub {
+		return a.c.List(ctx, list, opts...)
+	}
+
+	served, err := a.c.Scheme().New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return err
+	}
+	if err := a.c.List(ctx, served.(client.ObjectList), opts...); err != nil {
+		return err
+	}
+
+	items, err := meta.ExtractList(served)
+	if err != nil {
+		return err
+	}
+	out := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		obj, err := a.c.Scheme().New(hub)
+		if err != nil {
+			return err
+		}
+		if err := a.convert(item, obj); err != nil {
+			return err
+		}
+		out = append(out, obj)
+	}
+	if err := meta.SetList(list, out); err != nil {
+		return err
+	}
+	list.SetResourceVersion(served.(client.ObjectList).GetResourceVersion())
+	list.SetContinue(served.(client.ObjectList).GetContinue())
+	list.SetRemainingItemCount(served.(client.ObjectList).GetRemainingItemCount())
+	return nil
+}
+
+// Create saves obj, which must be of a hub type, in the api server.
+func (a *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return a.write(obj, func(o client.Object) error {
+		return a.c.Create(ctx, o, opts...)
+	})
+}
+
+// Update updates the given obj, which must be of a hub type, in the api server.
+func (a *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return a.write(obj, func(o client.Object) error {
+		return a.c.Update(ctx, o, opts...)
+	})
+}
+
+// Delete deletes the given obj, which must be of a hub type, from the api server.
+func (a *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	served, converted, err := a.served(obj)
+	if err != nil {
+		return err
+	}
+	if !converted {
+		return a.c.Delete(ctx, obj, opts...)
+	}
+	if err := a.convert(obj, served); err != nil {
+		return err
+	}
+	return a.c.Delete(ctx, served.(client.Object), opts...)
+}
+
+// write converts obj to the served version, calls fn and converts the result back into obj.
+func (a *Client) write(obj client.Object, fn func(o client.Object) error) error {
+	served, converted, err := a.served(obj)
+	if err != nil {
+		return err
+	}
+	if !converted {
+		return fn(obj)
+	}
+	if err := a.convert(obj, served); err != nil {
+		return err
+	}
+	if err := fn(served.(client.Object)); err != nil {
+		return err
+	}
+	return a.convert(served, obj)
+}
+
+// CreateOrPatch creates or patches obj, which must be of a hub type. The transform
+// function is called with the hub type, regardless of the version served by the cluster.
+func (a *Client) CreateOrPatch(ctx context.Context, obj client.Object, transform cu.TransformFunc, opts ...client.PatchOption) (kutil.VerbType, error) {
+	served, converted, err := a.served(obj)
+	if err != nil {
+		return kutil.VerbUnchanged, err
+	}
+	if !converted {
+		return cu.CreateOrPatch(ctx, a.c, obj, transform, opts...)
+	}
+	if err := a.convert(obj, served); err != nil {
+		return kutil.VerbUnchanged, err
+	}
+
+	hubGVK, err := apiutil.GVKForObject(obj, a.c.Scheme())
+	if err != nil {
+		return kutil.VerbUnchanged, err
+	}
+	servedGVK, err := apiutil.GVKForObject(served, a.c.Scheme())
+	if err != nil {
+		return kutil.VerbUnchanged, err
+	}
+	vt, err := cu.CreateOrPatchE(ctx, a.c, served.(client.Object), func(in client.Object, createOp bool) (client.Object, error) {
+		hub, err := a.c.Scheme().New(hubGVK)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.convert(in, hub); err != nil {
+			return nil, err
+		}
+		hub = transform(hub.(client.Object), createOp)
+		out, err := a.c.Scheme().New(servedGVK)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.convert(hub, out); err != nil {
+			return nil, err
+		}
+		return out.(client.Object), nil
+	}, opts...)
+	if err != nil {
+		return vt, err
+	}
+	return vt, a.convert(served, obj)
+}
@@ -0,0 +1,204 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adaptive
+
+import (
+	"context"
+	"testing"
+
+	kutil "kmodules.xyz/client-go"
+
+	"gomodules.xyz/pointer"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	core "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newClient(t *testing.T, served ...schema.GroupVersionKind) *Client {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []schema.GroupVersion
+	for _, gvk := range served {
+		versions = append(versions, gvk.GroupVersion())
+	}
+	mapper := meta.NewDefaultRESTMapper(versions)
+	for _, gvk := range served {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return New(fake.NewClientBuilder().WithScheme(s).WithRESTMapper(mapper).Build())
+}
+
+func TestClient_HorizontalPodAutoscaler(t *testing.T) {
+	ctx := context.TODO()
+	c := newClient(t, autoscalingv1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"))
+
+	gvk, err := c.ServedVersion(autoscalingv2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gvk.Version != "v1" {
+		t.Errorf("expected served version v1, got %v", gvk)
+	}
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}
+	vt, err := c.CreateOrPatch(ctx, hpa, func(obj client.Object, createOp bool) client.Object {
+		in := obj.(*autoscalingv2.HorizontalPodAutoscaler)
+		in.Spec.ScaleTargetRef = autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}
+		in.Spec.MaxReplicas = 5
+		in.Spec.Metrics = []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name: core.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{
+						Type:               autoscalingv2.UtilizationMetricType,
+						AverageUtilization: pointer.Int32P(80),
+					},
+				},
+			},
+		}
+		return in
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt != kutil.VerbCreated {
+		t.Errorf("expected %s, got %s", kutil.VerbCreated, vt)
+	}
+
+	var stored autoscalingv1.HorizontalPodAutoscaler
+	if err := c.c.Get(ctx, client.ObjectKeyFromObject(hpa), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if p := stored.Spec.TargetCPUUtilizationPercentage; p == nil || *p != 80 {
+		t.Errorf("expected stored cpu target 80, got %v", p)
+	}
+
+	var list autoscalingv2.HorizontalPodAutoscalerList
+	if err := c.List(ctx, &list, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Spec.MaxReplicas != 5 || len(list.Items[0].Spec.Metrics) != 1 {
+		t.Errorf("unexpected list %+v", list.Items)
+	}
+
+	vt, err = c.CreateOrPatch(ctx, hpa, func(obj client.Object, createOp bool) client.Object {
+		in := obj.(*autoscalingv2.HorizontalPodAutoscaler)
+		in.Spec.MaxReplicas = 10
+		return in
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt != kutil.VerbPatched {
+		t.Errorf("expected %s, got %s", kutil.VerbPatched, vt)
+	}
+
+	var got autoscalingv2.HorizontalPodAutoscaler
+	if err := c.Get(ctx, client.ObjectKeyFromObject(hpa), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.MaxReplicas != 10 {
+		t.Errorf("expected max replicas 10, got %d", got.Spec.MaxReplicas)
+	}
+
+	if err := c.Delete(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Ingress(t *testing.T) {
+	ctx := context.TODO()
+	c := newClient(t, extensions.SchemeGroupVersion.WithKind("Ingress"))
+
+	pathType := networkingv1.PathTypePrefix
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: "example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "web",
+											Port: networkingv1.ServiceBackendPort{Name: "http"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := c.Create(ctx, ing); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored extensions.Ingress
+	if err := c.c.Get(ctx, client.ObjectKeyFromObject(ing), &stored); err != nil {
+		t.Fatal(err)
+	}
+	backend := stored.Spec.Rules[0].HTTP.Paths[0].Backend
+	if backend.ServiceName != "web" || backend.ServicePort.StrVal != "http" {
+		t.Errorf("unexpected stored backend %+v", backend)
+	}
+
+	var got networkingv1.Ingress
+	if err := c.Get(ctx, client.ObjectKeyFromObject(ing), &got); err != nil {
+		t.Fatal(err)
+	}
+	svc := got.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	if svc == nil || svc.Name != "web" || svc.Port.Name != "http" {
+		t.Errorf("unexpected backend service %+v", svc)
+	}
+}
+
+func TestClient_NotServed(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.ServedVersion(networkingv1.SchemeGroupVersion.WithKind("Ingress"))
+	if !meta.IsNoMatchError(err) {
+		t.Errorf("expected no match error, got %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adaptive
+
+import (
+	"encoding/json"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	core "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DefaultVersions lists the versions of the built-in kinds supported by the Client, keyed by
+// the hub version. Versions are listed in the order of preference, hub version first.
+var DefaultVersions = map[schema.GroupVersionKind][]schema.GroupVersionKind{
+	batchv1.SchemeGroupVersion.WithKind("CronJob"): {
+		batchv1.SchemeGroupVersion.WithKind("CronJob"),
+		batchv1beta1.SchemeGroupVersion.WithKind("CronJob"),
+	},
+	policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget"): {
+		policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget"),
+		policyv1beta1.SchemeGroupVersion.WithKind("PodDisruptionBudget"),
+	},
+	autoscalingv2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"): {
+		autoscalingv2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"),
+		autoscalingv2beta2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"),
+		autoscalingv1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"),
+	},
+	networkingv1.SchemeGroupVersion.WithKind("Ingress"): {
+		networkingv1.SchemeGroupVersion.WithKind("Ingress"),
+		networkingv1beta1.SchemeGroupVersion.WithKind("Ingress"),
+		extensions.SchemeGroupVersion.WithKind("Ingress"),
+	},
+	discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"): {
+		discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"),
+		discoveryv1beta1.SchemeGroupVersion.WithKind("EndpointSlice"),
+	},
+}
+
+// AddToScheme registers the conversions between the hub and the other versions of the
+// kinds listed in DefaultVersions. The types themselves must be registered separately,
+// eg, using k8s.io/client-go/kubernetes/scheme.AddToScheme .
+func AddToScheme(s *runtime.Scheme) error {
+	fns := []func(*runtime.Scheme) error{
+		addCronJobConversions,
+		addPodDisruptionBudgetConversions,
+		addHorizontalPodAutoscalerConversions,
+		addIngressConversions,
+		addEndpointSliceConversions,
+	}
+	for _, fn := range fns {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddConversion registers a pair of conversion functions between a hub type and another version.
+func AddConversion[Hub, Spoke any](s *runtime.Scheme, toHub func(in *Spoke, out *Hub) error, fromHub func(in *Hub, out *Spoke) error) error {
+	if err := s.AddConversionFunc((*Spoke)(nil), (*Hub)(nil), func(a, b interface{}, _ conversion.Scope) error {
+		return toHub(a.(*Spoke), b.(*Hub))
+	}); err != nil {
+		return err
+	}
+	return s.AddConversionFunc((*Hub)(nil), (*Spoke)(nil), func(a, b interface{}, _ conversion.Scope) error {
+		return fromHub(a.(*Hub), b.(*Spoke))
+	})
+}
+
+// convertJSON converts between versions whose json representations are compatible.
+// The TypeMeta of out is preserved.
+func convertJSON(in, out interface{}) error {
+	obj, isObject := out.(runtime.Object)
+	var gvk schema.GroupVersionKind
+	if isObject {
+		gvk = obj.GetObjectKind().GroupVersionKind()
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return err
+	}
+	if isObject {
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+	return nil
+}
+
+func jsonConversion[Hub, Spoke any, HubP interface {
+	*Hub
+	runtime.Object
+}, SpokeP interface {
+	*Spoke
+	runtime.Object
+}](s *runtime.Scheme) error {
+	return AddConversion[Hub, Spoke](s,
+		func(in *Spoke, out *Hub) error { return convertJSON(SpokeP(in), HubP(out)) },
+		func(in *Hub, out *Spoke) error { return convertJSON(HubP(in), SpokeP(out)) },
+	)
+}
+
+func addCronJobConversions(s *runtime.Scheme) error {
+	return jsonConversion[batchv1.CronJob, batchv1beta1.CronJob](s)
+}
+
+func addPodDisruptionBudgetConversions(s *runtime.Scheme) error {
+	return jsonConversion[policyv1.PodDisruptionBudget, policyv1beta1.PodDisruptionBudget](s)
+}
+
+func addHorizontalPodAutoscalerConversions(s *runtime.Scheme) error {
+	if err := jsonConversion[autoscalingv2.HorizontalPodAutoscaler, autoscalingv2beta2.HorizontalPodAutoscaler](s); err != nil {
+		return err
+	}
+	return AddConversion(s, convert_hpa_v1_to_v2, convert_hpa_v2_to_v1)
+}
+
+// convert_hpa_v1_to_v2 converts an autoscaling/v1 HPA. autoscaling/v1 only supports
+// a cpu utilization target.
+func convert_hpa_v1_to_v2(in *autoscalingv1.HorizontalPodAutoscaler, out *autoscalingv2.HorizontalPodAutoscaler) error {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference(in.Spec.ScaleTargetRef),
+		MinReplicas:    in.Spec.MinReplicas,
+		MaxReplicas:    in.Spec.MaxReplicas,
+	}
+	if in.Spec.TargetCPUUtilizationPercentage != nil {
+		out.Spec.Metrics = []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name: core.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{
+						Type:               autoscalingv2.UtilizationMetricType,
+						AverageUtilization: in.Spec.TargetCPUUtilizationPercentage,
+					},
+				},
+			},
+		}
+	}
+	out.Status = autoscalingv2.HorizontalPodAutoscalerStatus{
+		ObservedGeneration: in.Status.ObservedGeneration,
+		LastScaleTime:      in.Status.LastScaleTime,
+		CurrentReplicas:    in.Status.CurrentReplicas,
+		DesiredReplicas:    in.Status.DesiredReplicas,
+	}
+	if in.Status.CurrentCPUUtilizationPercentage != nil {
+		out.Status.CurrentMetrics = []autoscalingv2.MetricStatus{
+			{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricStatus{
+					Name: core.ResourceCPU,
+					Current: autoscalingv2.MetricValueStatus{
+						AverageUtilization: in.Status.CurrentCPUUtilizationPercentage,
+					},
+				},
+			},
+		}
+	}
+	return nil
+}
+
+// convert_hpa_v2_to_v1 converts to an autoscaling/v1 HPA. Metrics other than the
+// cpu utilization target are dropped.
+func convert_hpa_v2_to_v1(in *autoscalingv2.HorizontalPodAutoscaler, out *autoscalingv1.HorizontalPodAutoscaler) error {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = autoscalingv1.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv1.CrossVersionObjectReference(in.Spec.ScaleTargetRef),
+		MinReplicas:    in.Spec.MinReplicas,
+		MaxReplicas:    in.Spec.MaxReplicas,
+	}
+	for _, m := range in.Spec.Metrics {
+		if m.Type == autoscalingv2.ResourceMetricSourceType &&
+			m.Resource != nil &&
+			m.Resource.Name == core.ResourceCPU &&
+			m.Resource.Target.Type == autoscalingv2.UtilizationMetricType {
+			out.Spec.TargetCPUUtilizationPercentage = m.Resource.Target.AverageUtilization
+		}
+	}
+	out.Status = autoscalingv1.HorizontalPodAutoscalerStatus{
+		ObservedGeneration: in.Status.ObservedGeneration,
+		LastScaleTime:      in.Status.LastScaleTime,
+		CurrentReplicas:    in.Status.CurrentReplicas,
+		DesiredReplicas:    in.Status.DesiredReplicas,
+	}
+	for _, m := range in.Status.CurrentMetrics {
+		if m.Type == autoscalingv2.ResourceMetricSourceType && m.Resource != nil && m.Resource.Name == core.ResourceCPU {
+			out.Status.CurrentCPUUtilizationPercentage = m.Resource.Current.AverageUtilization
+		}
+	}
+	return nil
+}
+
+func addIngressConversions(s *runtime.Scheme) error {
+	if err := AddConversion(s, convert_ingress_v1beta1_to_v1, convert_ingress_v1_to_v1beta1); err != nil {
+		return err
+	}
+	return AddConversion(s,
+		func(in *extensions.Ingress, out *networkingv1.Ingress) error {
+			var tmp networkingv1beta1.Ingress
+			if err := convertJSON(in, &tmp); err != nil {
+				return err
+			}
+			return convert_ingress_v1beta1_to_v1(&tmp, out)
+		},
+		func(in *networkingv1.Ingress, out *extensions.Ingress) error {
+			var tmp networkingv1beta1.Ingress
+			if err := convert_ingress_v1_to_v1beta1(in, &tmp); err != nil {
+				return err
+			}
+			return convertJSON(&tmp, out)
+		},
+	)
+}
+
+func convert_ingress_v1beta1_to_v1(in *networkingv1beta1.Ingress, out *networkingv1.Ingress) error {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = networkingv1.IngressSpec{
+		IngressClassName: in.Spec.IngressClassName,
+		DefaultBackend:   convert_backend_v1beta1_to_v1(in.Spec.Backend),
+	}
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1.IngressTLS(tls))
+	}
+	for _, rule := range in.Spec.Rules {
+		r := networkingv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &networkingv1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				pathType := networkingv1.PathTypeImplementationSpecific
+				if p.PathType != nil {
+					pathType = networkingv1.PathType(*p.PathType)
+				}
+				r.HTTP.Paths = append(r.HTTP.Paths, networkingv1.HTTPIngressPath{
+					Path:     p.Path,
+					PathType: &pathType,
+					Backend:  *convert_backend_v1beta1_to_v1(&p.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+	return convertJSON(&in.Status, &out.Status)
+}
+
+func convert_ingress_v1_to_v1beta1(in *networkingv1.Ingress, out *networkingv1beta1.Ingress) error {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = networkingv1beta1.IngressSpec{
+		IngressClassName: in.Spec.IngressClassName,
+		Backend:          convert_backend_v1_to_v1beta1(in.Spec.DefaultBackend),
+	}
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1beta1.IngressTLS(tls))
+	}
+	for _, rule := range in.Spec.Rules {
+		r := networkingv1beta1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &networkingv1beta1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				var pathType *networkingv1beta1.PathType
+				if p.PathType != nil {
+					pt := networkingv1beta1.PathType(*p.PathType)
+					pathType = &pt
+				}
+				r.HTTP.Paths = append(r.HTTP.Paths, networkingv1beta1.HTTPIngressPath{
+					Path:     p.Path,
+					PathType: pathType,
+					Backend:  *convert_backend_v1_to_v1beta1(&p.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+	return convertJSON(&in.Status, &out.Status)
+}
+
+func convert_backend_v1beta1_to_v1(in *networkingv1beta1.IngressBackend) *networkingv1.IngressBackend {
+	if in == nil {
+		return nil
+	}
+	out := &networkingv1.IngressBackend{
+		Resource: in.Resource,
+	}
+	if in.ServiceName != "" {
+		out.Service = &networkingv1.IngressServiceBackend{Name: in.ServiceName}
+		if in.ServicePort.Type == intstr.String {
+			out.Service.Port.Name = in.ServicePort.StrVal
+		} else {
+			out.Service.Port.Number = in.ServicePort.IntVal
+		}
+	}
+	return out
+}
+
+func convert_backend_v1_to_v1beta1(in *networkingv1.IngressBackend) *networkingv1beta1.IngressBackend {
+	if in == nil {
+		return nil
+	}
+	out := &networkingv1beta1.IngressBackend{
+		Resource: in.Resource,
+	}
+	if in.Service != nil {
+		out.ServiceName = in.Service.Name
+		if in.Service.Port.Name != "" {
+			out.ServicePort = intstr.FromString(in.Service.Port.Name)
+		} else {
+			out.ServicePort = intstr.FromInt32(in.Service.Port.Number)
+		}
+	}
+	return out
+}
+
+func addEndpointSliceConversions(s *runtime.Scheme) error {
+	return AddConversion(s, convert_endpointslice_v1beta1_to_v1, convert_endpointslice_v1_to_v1beta1)
+}
+
+func convert_endpointslice_v1beta1_to_v1(in *discoveryv1beta1.EndpointSlice, out *discoveryv1.EndpointSlice) error {
+	if err := convertJSON(in, out); err != nil {
+		return err
+	}
+	for i, ep := range in.Endpoints {
+		if len(ep.Topology) == 0 {
+			continue
+		}
+		topology := make(map[string]string, len(ep.Topology))
+		for k, v := range ep.Topology {
+			switch k {
+			case core.LabelHostname:
+				if out.Endpoints[i].NodeName == nil {
+					out.Endpoints[i].NodeName = &v
+				}
+			case core.LabelTopologyZone:
+				if out.Endpoints[i].Zone == nil {
+					out.Endpoints[i].Zone = &v
+				}
+			default:
+				topology[k] = v
+			}
+		}
+		out.Endpoints[i].DeprecatedTopology = topology
+	}
+	return nil
+}
+
+func convert_endpointslice_v1_to_v1beta1(in *discoveryv1.EndpointSlice, out *discoveryv1beta1.EndpointSlice) error {
+	if err := convertJSON(in, out); err != nil {
+		return err
+	}
+	for i, ep := range in.Endpoints {
+		topology := make(map[string]string, len(ep.DeprecatedTopology)+2)
+		for k, v := range ep.DeprecatedTopology {
+			topology[k] = v
+		}
+		if ep.NodeName != nil {
+			topology[core.LabelHostname] = *ep.NodeName
+		}
+		if ep.Zone != nil {
+			topology[core.LabelTopologyZone] = *ep.Zone
+		}
+		if len(topology) > 0 {
+			out.Endpoints[i].Topology = topology
+		}
+	}
+	return nil
+}
@@ -192,6 +192,22 @@ func (drm *dynamicCachable) checkAndReload(checkNeedsReload func() error) error
 	return checkNeedsReload()
 }
 
+// Reset discards the discovered resource types and discovers them again, regardless of
+// reload backoff. Unlike the reload on a NoMatch error, it also notices resource types
+// that are no longer served.
+func (drm *dynamicCachable) Reset() error {
+	drm.mu.Lock()
+	defer drm.mu.Unlock()
+
+	if err := drm.setStaticCachable(); err != nil {
+		return err
+	}
+	if drm.lazy {
+		atomic.StoreUint32(&drm.inited, 1)
+	}
+	return nil
+}
+
 func (drm *dynamicCachable) GVK(gvk schema.GroupVersionKind) (bool, error) {
 	if err := drm.init(); err != nil {
 		return false, err
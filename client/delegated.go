@@ -19,12 +19,17 @@ package client
 import (
 	"context"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	apiutil2 "kmodules.xyz/client-go/client/apiutil"
 
+	kerr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -47,6 +52,28 @@ type NewDelegatingClientInput struct {
 	UncachedObjects   []client.Object
 	CacheUnstructured bool
 	Cachable          apiutil2.Cachable
+	// ScopedCaches routes reads of the given objects to caches that only hold a subset
+	// of them. Reads outside the scope of every cache of a type go to the api server.
+	ScopedCaches []ScopedCache
+}
+
+// ScopedCache is a cache that holds the objects of a type from a set of namespaces and/or
+// matching a label selector.
+type ScopedCache struct {
+	Object client.Object
+	Reader client.Reader
+	// Namespaces cached by Reader. Empty means all namespaces.
+	Namespaces []string
+	// LabelSelector used to fill the cache. Nil means all objects.
+	LabelSelector labels.Selector
+}
+
+// CacheStats reports how many reads of a type were served from a cache and how many
+// went to the api server.
+type CacheStats struct {
+	GVK    schema.GroupVersionKind
+	Hits   uint64
+	Misses uint64
 }
 
 // NewDelegatingClient creates a new delegating client.
@@ -63,6 +90,14 @@ func NewDelegatingClient(in NewDelegatingClientInput) (client.Client, error) {
 		}
 		uncachedGVKs[gvk] = struct{}{}
 	}
+	scopes := map[schema.GroupVersionKind][]ScopedCache{}
+	for _, sc := range in.ScopedCaches {
+		gvk, err := apiutil.GVKForObject(sc.Object, in.Client.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		scopes[gvk] = append(scopes[gvk], sc)
+	}
 
 	return &DelegatingClient{
 		config:  in.config,
@@ -76,6 +111,8 @@ func NewDelegatingClient(in NewDelegatingClientInput) (client.Client, error) {
 			uncachedGVKs:      uncachedGVKs,
 			cacheUnstructured: in.CacheUnstructured,
 			cachable:          in.Cachable,
+			scopes:            scopes,
+			stats:             map[schema.GroupVersionKind]*cacheCounter{},
 		},
 		Writer:                       in.Client,
 		StatusClient:                 in.Client,
@@ -145,6 +182,15 @@ func (d *DelegatingClient) RESTMapper() meta.RESTMapper {
 	return d.mapper
 }
 
+// CacheStats returns the cache hit and miss counts per GVK, sorted by GVK.
+func (d *DelegatingClient) CacheStats() []CacheStats {
+	r, ok := d.Reader.(*delegatingReader)
+	if !ok {
+		return nil
+	}
+	return r.cacheStats()
+}
+
 // delegatingReader forms a Reader that will cause Get and List requests for
 // unstructured types to use the ClientReader while requests for any other type
 // of object with use the CacheReader.  This avoids accidentally caching the
@@ -158,18 +204,31 @@ type delegatingReader struct {
 	scheme            *runtime.Scheme
 	cacheUnstructured bool
 	cachable          apiutil2.Cachable
+	scopes            map[schema.GroupVersionKind][]ScopedCache
+
+	statsMu sync.RWMutex
+	stats   map[schema.GroupVersionKind]*cacheCounter
+}
+
+type cacheCounter struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
-func (d *delegatingReader) shouldBypassCache(obj runtime.Object) (bool, error) {
+func (d *delegatingReader) gvkFor(obj runtime.Object) (schema.GroupVersionKind, error) {
 	gvk, err := apiutil.GVKForObject(obj, d.scheme)
 	if err != nil {
-		return false, err
+		return schema.GroupVersionKind{}, err
 	}
 	// TODO: this is producing unsafe guesses that don't actually work,
 	// but it matches ~99% of the cases out there.
 	if meta.IsListType(obj) {
 		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
 	}
+	return gvk, nil
+}
+
+func (d *delegatingReader) shouldBypassCache(gvk schema.GroupVersionKind, obj runtime.Object) (bool, error) {
 	if d.cachable != nil {
 		canCache, err := d.cachable.GVK(gvk)
 		if err != nil || !canCache {
@@ -187,23 +246,147 @@ func (d *delegatingReader) shouldBypassCache(obj runtime.Object) (bool, error) {
 	return false, nil
 }
 
+func (d *delegatingReader) record(gvk schema.GroupVersionKind, hit bool) {
+	d.statsMu.RLock()
+	c, ok := d.stats[gvk]
+	d.statsMu.RUnlock()
+	if !ok {
+		d.statsMu.Lock()
+		c, ok = d.stats[gvk]
+		if !ok {
+			c = &cacheCounter{}
+			d.stats[gvk] = c
+		}
+		d.statsMu.Unlock()
+	}
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (d *delegatingReader) cacheStats() []CacheStats {
+	d.statsMu.RLock()
+	defer d.statsMu.RUnlock()
+
+	result := make([]CacheStats, 0, len(d.stats))
+	for gvk, c := range d.stats {
+		result = append(result, CacheStats{
+			GVK:    gvk,
+			Hits:   c.hits.Load(),
+			Misses: c.misses.Load(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GVK.String() < result[j].GVK.String()
+	})
+	return result
+}
+
+// coversNamespace returns true if the cache holds objects from the given namespace.
+// An empty namespace means all namespaces.
+func (sc ScopedCache) coversNamespace(ns string) bool {
+	if len(sc.Namespaces) == 0 {
+		return true
+	}
+	if ns == "" {
+		return false
+	}
+	for _, n := range sc.Namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// coversSelector returns true if every object matching sel is in the cache, ie, sel
+// includes all the requirements of the label selector used to fill the cache.
+func (sc ScopedCache) coversSelector(sel labels.Selector) bool {
+	if sc.LabelSelector == nil || sc.LabelSelector.Empty() {
+		return true
+	}
+	if sel == nil {
+		return false
+	}
+	required, _ := sc.LabelSelector.Requirements()
+	given, _ := sel.Requirements()
+	for _, r := range required {
+		found := false
+		for _, g := range given {
+			if r.Equal(g) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // Get retrieves an obj for a given object key from the Kubernetes Cluster.
 func (d *delegatingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
-	if isUncached, err := d.shouldBypassCache(obj); err != nil {
+	gvk, err := d.gvkFor(obj)
+	if err != nil {
+		return err
+	}
+
+	if scopes, ok := d.scopes[gvk]; ok {
+		for _, sc := range scopes {
+			if !sc.coversNamespace(key.Namespace) {
+				continue
+			}
+			err := sc.Reader.Get(ctx, key, obj, opts...)
+			if err == nil || !kerr.IsNotFound(err) || sc.LabelSelector == nil || sc.LabelSelector.Empty() {
+				d.record(gvk, true)
+				return err
+			}
+			// the object may exist but not match the label selector of the cache
+		}
+		d.record(gvk, false)
+		return d.ClientReader.Get(ctx, key, obj, opts...)
+	}
+
+	if isUncached, err := d.shouldBypassCache(gvk, obj); err != nil {
 		return err
 	} else if isUncached {
+		d.record(gvk, false)
 		return d.ClientReader.Get(ctx, key, obj, opts...)
 	}
+	d.record(gvk, true)
 	return d.CacheReader.Get(ctx, key, obj, opts...)
 }
 
 // List retrieves list of objects for a given namespace and list options.
 func (d *delegatingReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
-	if isUncached, err := d.shouldBypassCache(list); err != nil {
+	gvk, err := d.gvkFor(list)
+	if err != nil {
+		return err
+	}
+
+	if scopes, ok := d.scopes[gvk]; ok {
+		listOpts := client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		for _, sc := range scopes {
+			if sc.coversNamespace(listOpts.Namespace) && sc.coversSelector(listOpts.LabelSelector) {
+				d.record(gvk, true)
+				return sc.Reader.List(ctx, list, opts...)
+			}
+		}
+		d.record(gvk, false)
+		return d.ClientReader.List(ctx, list, opts...)
+	}
+
+	if isUncached, err := d.shouldBypassCache(gvk, list); err != nil {
 		return err
 	} else if isUncached {
+		d.record(gvk, false)
 		return d.ClientReader.List(ctx, list, opts...)
 	}
+	d.record(gvk, true)
 	return d.CacheReader.List(ctx, list, opts...)
 }
 
@@ -0,0 +1,104 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPod(ns, name string, lbls map[string]string) *core.Pod {
+	return &core.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      name,
+			Labels:    lbls,
+		},
+	}
+}
+
+func TestDelegatingClient_ScopedCaches(t *testing.T) {
+	ctx := context.TODO()
+
+	managed := map[string]string{"app.kubernetes.io/managed-by": "kubedb"}
+	podA := newPod("demo", "a", managed)
+	podB := newPod("demo", "b", nil)
+	podC := newPod("other", "c", managed)
+
+	live := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(podA, podB, podC).Build()
+	// the cache only holds the managed pods of the demo namespace
+	cache := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(podA).Build()
+
+	c, err := NewDelegatingClient(NewDelegatingClientInput{
+		Client: live,
+		ScopedCaches: []ScopedCache{
+			{
+				Object:        &core.Pod{},
+				Reader:        cache,
+				Namespaces:    []string{"demo"},
+				LabelSelector: labels.SelectorFromSet(managed),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []client.ObjectKey{
+		client.ObjectKeyFromObject(podA), // hit
+		client.ObjectKeyFromObject(podB), // not in cache, falls back to live read
+		client.ObjectKeyFromObject(podC), // namespace not cached
+	} {
+		var pod core.Pod
+		if err := c.Get(ctx, key, &pod); err != nil {
+			t.Errorf("failed to get pod %s: %v", key, err)
+		}
+	}
+
+	cases := []struct {
+		opts     []client.ListOption
+		expected int
+	}{
+		{[]client.ListOption{client.InNamespace("demo"), client.MatchingLabels(managed)}, 1}, // hit
+		{[]client.ListOption{client.InNamespace("demo")}, 2},                                 // selector outside scope
+		{[]client.ListOption{client.MatchingLabels(managed)}, 2},                             // namespace outside scope
+	}
+	for i, tc := range cases {
+		var list core.PodList
+		if err := c.List(ctx, &list, tc.opts...); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Items) != tc.expected {
+			t.Errorf("case %d: expected %d pods, got %d", i, tc.expected, len(list.Items))
+		}
+	}
+
+	stats := c.(*DelegatingClient).CacheStats()
+	if len(stats) != 1 {
+		t.Fatalf("expected stats for 1 GVK, got %+v", stats)
+	}
+	if stats[0].Hits != 2 || stats[0].Misses != 4 {
+		t.Errorf("expected 2 hits and 4 misses, got %+v", stats[0])
+	}
+}
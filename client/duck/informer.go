@@ -0,0 +1,340 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duck
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	errors2 "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Informer merges the informers of the underlying types into a single stream of duck objects.
+type Informer interface {
+	// AddEventHandler adds an event handler that is called with duck objects for the
+	// events of all the underlying types.
+	AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error)
+	// RemoveEventHandler removes a handler added using AddEventHandler.
+	RemoveEventHandler(handle toolscache.ResourceEventHandlerRegistration) error
+	// AddIndexers adds indexers to the store of duck objects. Index functions are called with duck objects.
+	AddIndexers(indexers toolscache.Indexers) error
+	// ByIndex returns the duck objects whose indexed values include the given value.
+	ByIndex(indexName, indexedValue string) ([]Object, error)
+	// List returns the duck objects in the given namespace matching the selector.
+	// Use an empty namespace to list objects from all namespaces.
+	List(namespace string, selector labels.Selector) ([]Object, error)
+	// HasSynced returns true once the objects of all the underlying types have been added to the store.
+	HasSynced() bool
+	// WaitForCacheSync waits until HasSynced returns true or ctx is done.
+	WaitForCacheSync(ctx context.Context) bool
+}
+
+// InformerGetter returns the informer for a type. It is implemented by the controller-runtime cache.
+type InformerGetter interface {
+	GetInformer(ctx context.Context, obj client.Object, opts ...cache.InformerGetOption) (cache.Informer, error)
+}
+
+type typedInformer struct {
+	gvk      schema.GroupVersionKind
+	informer cache.Informer
+	// indexer stores the duck objects converted from the underlying type.
+	indexer toolscache.Indexer
+	// synced returns true once the initial list has been added to the indexer.
+	synced toolscache.InformerSynced
+}
+
+type InformerImpl struct {
+	scheme    *runtime.Scheme
+	duckObj   Object
+	duckGVK   schema.GroupVersionKind
+	rawObjs   []client.Object
+	informers []*typedInformer
+}
+
+var _ Informer = &InformerImpl{}
+
+type InformerBuilder struct {
+	cc *InformerImpl
+}
+
+func NewInformer() *InformerBuilder {
+	return &InformerBuilder{
+		cc: new(InformerImpl),
+	}
+}
+
+func (b *InformerBuilder) ForDuckType(obj Object) *InformerBuilder {
+	b.cc.duckObj = obj
+	return b
+}
+
+func (b *InformerBuilder) WithUnderlyingTypes(objs client.Object, rest ...client.Object) *InformerBuilder {
+	b.cc.rawObjs = make([]client.Object, 0, len(rest)+1)
+	b.cc.rawObjs = append(b.cc.rawObjs, objs)
+	b.cc.rawObjs = append(b.cc.rawObjs, rest...)
+	return b
+}
+
+// Build gets the informers of the underlying types from ig, eg, the cache of a manager.
+// The informers are started by ig.
+func (b *InformerBuilder) Build(ctx context.Context, ig InformerGetter, scheme *runtime.Scheme) (Informer, error) {
+	if b.cc.duckObj == nil {
+		return nil, fmt.Errorf("must provide a duck type")
+	}
+	if len(b.cc.rawObjs) == 0 {
+		return nil, fmt.Errorf("must provide underlying types")
+	}
+
+	b.cc.scheme = scheme
+	gvk, err := apiutil.GVKForObject(b.cc.duckObj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	b.cc.duckGVK = gvk
+
+	for _, rawObj := range b.cc.rawObjs {
+		rawGVK, err := apiutil.GVKForObject(rawObj, scheme)
+		if err != nil {
+			return nil, err
+		}
+
+		var obj client.Object
+		if _, isUnstructured := rawObj.(*unstructured.Unstructured); isUnstructured {
+			var u unstructured.Unstructured
+			u.GetObjectKind().SetGroupVersionKind(rawGVK)
+			obj = &u
+		} else {
+			obj = rawObj
+		}
+		informer, err := ig.GetInformer(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		ti := &typedInformer{
+			gvk:      rawGVK,
+			informer: informer,
+			indexer: toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{
+				toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+			}),
+		}
+		reg, err := informer.AddEventHandler(b.cc.duckHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				_ = ti.indexer.Add(obj)
+			},
+			UpdateFunc: func(_, newObj interface{}) {
+				_ = ti.indexer.Update(newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if d, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+					obj = d.Obj
+				}
+				_ = ti.indexer.Delete(obj)
+			},
+		}))
+		if err != nil {
+			return nil, err
+		}
+		ti.synced = reg.HasSynced
+		b.cc.informers = append(b.cc.informers, ti)
+	}
+	return b.cc, nil
+}
+
+// Scheme returns the scheme this informer is using.
+func (d *InformerImpl) Scheme() *runtime.Scheme {
+	return d.scheme
+}
+
+func (d *InformerImpl) duckify(obj interface{}) (Object, error) {
+	raw, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("expected runtime.Object, got %T", obj)
+	}
+	d2, err := d.scheme.New(d.duckGVK)
+	if err != nil {
+		return nil, err
+	}
+	dd := d2.(Object)
+	if err := dd.Duckify(raw); err != nil {
+		return nil, err
+	}
+	return dd, nil
+}
+
+// duckHandler returns a handler for the underlying types that converts objects to the duck type
+// before calling h. Objects that fail to convert are skipped.
+func (d *InformerImpl) duckHandler(h toolscache.ResourceEventHandler) toolscache.ResourceEventHandler {
+	return toolscache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			dd, err := d.duckify(obj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			h.OnAdd(dd, isInInitialList)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldDuck, err := d.duckify(oldObj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			newDuck, err := d.duckify(newObj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			h.OnUpdate(oldDuck, newDuck)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				dd, err := d.duckify(tombstone.Obj)
+				if err != nil {
+					utilruntime.HandleError(err)
+					return
+				}
+				h.OnDelete(toolscache.DeletedFinalStateUnknown{Key: tombstone.Key, Obj: dd})
+				return
+			}
+			dd, err := d.duckify(obj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			h.OnDelete(dd)
+		},
+	}
+}
+
+// registration groups the registrations of a handler with the informers of the underlying types.
+type registration struct {
+	handles map[*typedInformer]toolscache.ResourceEventHandlerRegistration
+}
+
+func (r *registration) HasSynced() bool {
+	for _, h := range r.handles {
+		if !h.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *InformerImpl) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	reg := &registration{
+		handles: make(map[*typedInformer]toolscache.ResourceEventHandlerRegistration, len(d.informers)),
+	}
+	for _, ti := range d.informers {
+		h, err := ti.informer.AddEventHandler(d.duckHandler(handler))
+		if err != nil {
+			_ = d.RemoveEventHandler(reg)
+			return nil, err
+		}
+		reg.handles[ti] = h
+	}
+	return reg, nil
+}
+
+func (d *InformerImpl) RemoveEventHandler(handle toolscache.ResourceEventHandlerRegistration) error {
+	reg, ok := handle.(*registration)
+	if !ok {
+		return fmt.Errorf("unknown registration type %T", handle)
+	}
+	var errs []error
+	for ti, h := range reg.handles {
+		if err := ti.informer.RemoveEventHandler(h); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors2.NewAggregate(errs)
+}
+
+func (d *InformerImpl) AddIndexers(indexers toolscache.Indexers) error {
+	for _, ti := range d.informers {
+		if err := ti.indexer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *InformerImpl) ByIndex(indexName, indexedValue string) ([]Object, error) {
+	var result []Object
+	for _, ti := range d.informers {
+		items, err := ti.indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			result = append(result, item.(Object))
+		}
+	}
+	return result, nil
+}
+
+func (d *InformerImpl) List(namespace string, selector labels.Selector) ([]Object, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	var result []Object
+	for _, ti := range d.informers {
+		var items []interface{}
+		if namespace == "" {
+			items = ti.indexer.List()
+		} else {
+			var err error
+			items, err = ti.indexer.ByIndex(toolscache.NamespaceIndex, namespace)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, item := range items {
+			obj := item.(Object)
+			if selector.Matches(labels.Set(obj.GetLabels())) {
+				result = append(result, obj)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *InformerImpl) HasSynced() bool {
+	for _, ti := range d.informers {
+		if !ti.synced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *InformerImpl) WaitForCacheSync(ctx context.Context) bool {
+	synced := make([]toolscache.InformerSynced, 0, len(d.informers))
+	for _, ti := range d.informers {
+		synced = append(synced, ti.synced)
+	}
+	return toolscache.WaitForCacheSync(ctx.Done(), synced...)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duck
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type workload struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Replicas          int32 `json:"replicas"`
+}
+
+func (w *workload) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func (w *workload) Duckify(srcRaw runtime.Object) error {
+	switch src := srcRaw.(type) {
+	case *apps.Deployment:
+		src.ObjectMeta.DeepCopyInto(&w.ObjectMeta)
+		w.Replicas = *src.Spec.Replicas
+	case *apps.StatefulSet:
+		src.ObjectMeta.DeepCopyInto(&w.ObjectMeta)
+		w.Replicas = *src.Spec.Replicas
+	default:
+		return fmt.Errorf("unknown src type %T", srcRaw)
+	}
+	return nil
+}
+
+type factoryInformers struct {
+	factory informers.SharedInformerFactory
+}
+
+func (f factoryInformers) GetInformer(_ context.Context, obj client.Object, _ ...cache.InformerGetOption) (cache.Informer, error) {
+	switch obj.(type) {
+	case *apps.Deployment:
+		return f.factory.Apps().V1().Deployments().Informer(), nil
+	case *apps.StatefulSet:
+		return f.factory.Apps().V1().StatefulSets().Informer(), nil
+	}
+	return nil, fmt.Errorf("unknown type %T", obj)
+}
+
+func TestInformer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: "duck.test", Version: "v1", Kind: "Workload"}, &workload{})
+
+	replicas := int32(3)
+	lbls := map[string]string{"app": "db"}
+	kc := fake.NewSimpleClientset(
+		&apps.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "demo", Labels: lbls},
+			Spec:       apps.DeploymentSpec{Replicas: &replicas},
+		},
+		&apps.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "demo", Labels: lbls},
+			Spec:       apps.StatefulSetSpec{Replicas: &replicas},
+		},
+	)
+	factory := informers.NewSharedInformerFactory(kc, 0)
+
+	inf, err := NewInformer().
+		ForDuckType(&workload{}).
+		WithUnderlyingTypes(&apps.Deployment{}, &apps.StatefulSet{}).
+		Build(ctx, factoryInformers{factory: factory}, scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = inf.AddIndexers(toolscache.Indexers{
+		"replicas": func(obj interface{}) ([]string, error) {
+			return []string{fmt.Sprint(obj.(*workload).Replicas)}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan string, 10)
+	_, err = inf.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			events <- "add/" + obj.(*workload).Name
+		},
+		DeleteFunc: func(obj interface{}) {
+			events <- "delete/" + obj.(*workload).Name
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factory.Start(ctx.Done())
+	if !inf.WaitForCacheSync(ctx) {
+		t.Fatal("failed to sync informer")
+	}
+
+	objs, err := inf.List("demo", labels.SelectorFromSet(lbls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Errorf("expected 2 duck objects, got %d", len(objs))
+	}
+	objs, err = inf.ByIndex("replicas", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Errorf("expected 2 duck objects with 3 replicas, got %d", len(objs))
+	}
+
+	if err := kc.AppsV1().StatefulSets("demo").Delete(ctx, "db", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	timeout := time.After(wait.ForeverTestTimeout)
+	for got["add/db"] < 2 || got["delete/db"] < 1 {
+		select {
+		case e := <-events:
+			got[e]++
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+	if err := waitFor(func() bool {
+		objs, _ := inf.List("", nil)
+		return len(objs) == 1
+	}); err != nil {
+		t.Errorf("expected the deleted StatefulSet to be removed from the store")
+	}
+}
+
+func waitFor(cond func() bool) error {
+	return wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, wait.ForeverTestTimeout, true, func(context.Context) (bool, error) {
+		return cond(), nil
+	})
+}
@@ -0,0 +1,304 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duck
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldMapping maps the field paths of a duck type to the field paths of an underlying type.
+// Paths use the jsonpath field syntax without array indices, eg, {.spec.replicas} or
+// .spec.template.spec.containers . The metadata of the objects is always copied.
+type FieldMapping map[string]string
+
+// FieldMapped is implemented by duck types that are converted using a FieldMapper. Patches of
+// FieldMapped duck types are translated to the field paths of the underlying type.
+type FieldMapped interface {
+	FieldMapper() *FieldMapper
+}
+
+type fieldPath struct {
+	duck []string
+	raw  []string
+}
+
+// FieldMapper converts objects of the underlying types to a duck type using a FieldMapping for
+// each underlying GVK. This allows unstructured objects and third party CRDs to be adapted to
+// a duck type without writing a converter per underlying kind. A duck type can implement Duckify
+// by calling FieldMapper.Duckify .
+type FieldMapper struct {
+	scheme   *runtime.Scheme
+	mappings map[schema.GroupVersionKind][]fieldPath
+}
+
+// NewFieldMapper returns a FieldMapper for the given mappings. The scheme is used to detect the
+// GVK of typed objects without TypeMeta and may be nil if only unstructured objects are used.
+func NewFieldMapper(scheme *runtime.Scheme, mappings map[schema.GroupVersionKind]FieldMapping) (*FieldMapper, error) {
+	m := &FieldMapper{
+		scheme:   scheme,
+		mappings: make(map[schema.GroupVersionKind][]fieldPath, len(mappings)),
+	}
+	for gvk, mapping := range mappings {
+		paths := make([]fieldPath, 0, len(mapping))
+		for duckPath, rawPath := range mapping {
+			fp := fieldPath{
+				duck: parseFieldPath(duckPath),
+				raw:  parseFieldPath(rawPath),
+			}
+			if len(fp.duck) == 0 || len(fp.raw) == 0 {
+				return nil, fmt.Errorf("invalid field mapping %q: %q for %v", duckPath, rawPath, gvk)
+			}
+			if fp.duck[0] == "metadata" || fp.raw[0] == "metadata" {
+				return nil, fmt.Errorf("metadata can't be mapped for %v", gvk)
+			}
+			paths = append(paths, fp)
+		}
+		// deterministic order, parent paths before child paths
+		sort.Slice(paths, func(i, j int) bool {
+			return strings.Join(paths[i].duck, ".") < strings.Join(paths[j].duck, ".")
+		})
+		m.mappings[gvk] = paths
+	}
+	return m, nil
+}
+
+// MustFieldMapper is like NewFieldMapper but panics on error. It simplifies the initialization
+// of package level variables.
+func MustFieldMapper(scheme *runtime.Scheme, mappings map[schema.GroupVersionKind]FieldMapping) *FieldMapper {
+	m, err := NewFieldMapper(scheme, mappings)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func parseFieldPath(p string) []string {
+	p = strings.TrimSpace(p)
+	p = strings.TrimPrefix(p, "{")
+	p = strings.TrimSuffix(p, "}")
+	p = strings.TrimPrefix(p, ".")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, ".")
+}
+
+func (m *FieldMapper) pathsFor(gvk schema.GroupVersionKind) ([]fieldPath, error) {
+	paths, ok := m.mappings[gvk]
+	if !ok {
+		return nil, fmt.Errorf("no field mapping found for %v", gvk)
+	}
+	return paths, nil
+}
+
+func (m *FieldMapper) gvkFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() || m.scheme == nil {
+		return gvk, nil
+	}
+	return apiutil.GVKForObject(obj, m.scheme)
+}
+
+// Duckify converts src, an object of an underlying type, into dst.
+func (m *FieldMapper) Duckify(src runtime.Object, dst runtime.Object) error {
+	gvk, err := m.gvkFor(src)
+	if err != nil {
+		return err
+	}
+	paths, err := m.pathsFor(gvk)
+	if err != nil {
+		return err
+	}
+
+	var in map[string]interface{}
+	if u, ok := src.(runtime.Unstructured); ok {
+		in = u.UnstructuredContent()
+	} else {
+		in, err = runtime.DefaultUnstructuredConverter.ToUnstructured(src)
+		if err != nil {
+			return err
+		}
+	}
+
+	out := map[string]interface{}{}
+	if md, ok := in["metadata"]; ok {
+		out["metadata"] = runtime.DeepCopyJSONValue(md)
+	}
+	for _, fp := range paths {
+		v, found, err := unstructured.NestedFieldNoCopy(in, fp.raw...)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err := unstructured.SetNestedField(out, v, fp.duck...); err != nil {
+			return err
+		}
+	}
+
+	if u, ok := dst.(runtime.Unstructured); ok {
+		u.SetUnstructuredContent(out)
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(out, dst)
+}
+
+// ConvertPatch converts a patch of the duck object obj into a patch of the underlying type rawGVK.
+// Merge, strategic merge, apply and json patches are supported.
+func (m *FieldMapper) ConvertPatch(rawGVK schema.GroupVersionKind, obj client.Object, patch client.Patch) (client.Patch, error) {
+	paths, err := m.pathsFor(rawGVK)
+	if err != nil {
+		return nil, err
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	switch patch.Type() {
+	case types.MergePatchType, types.StrategicMergePatchType, types.ApplyPatchType:
+		data, err = convertMergePatch(paths, rawGVK, patch.Type(), data)
+	case types.JSONPatchType:
+		data, err = convertJSONPatch(paths, data)
+	default:
+		err = fmt.Errorf("unsupported patch type %s", patch.Type())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RawPatch{
+		pt:   patch.Type(),
+		data: data,
+	}, nil
+}
+
+func convertMergePatch(paths []fieldPath, rawGVK schema.GroupVersionKind, pt types.PatchType, data []byte) ([]byte, error) {
+	var in map[string]interface{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+	if md, ok := in["metadata"]; ok {
+		out["metadata"] = md
+	}
+	if pt == types.ApplyPatchType {
+		out["apiVersion"], out["kind"] = rawGVK.ToAPIVersionAndKind()
+	}
+	delete(in, "metadata")
+	delete(in, "apiVersion")
+	delete(in, "kind")
+
+	for _, fp := range paths {
+		v, found, err := unstructured.NestedFieldNoCopy(in, fp.duck...)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if err := unstructured.SetNestedField(out, v, fp.raw...); err != nil {
+			return nil, err
+		}
+		unstructured.RemoveNestedField(in, fp.duck...)
+	}
+
+	if unmapped := leafPaths(in, nil); len(unmapped) > 0 {
+		return nil, fmt.Errorf("patch includes fields %v not mapped to %v", unmapped, rawGVK)
+	}
+	return json.Marshal(out)
+}
+
+// leafPaths returns the paths of the non map values in m. Empty maps are ignored.
+func leafPaths(m map[string]interface{}, prefix []string) []string {
+	var result []string
+	for k, v := range m {
+		p := append(append([]string(nil), prefix...), k)
+		if child, ok := v.(map[string]interface{}); ok {
+			result = append(result, leafPaths(child, p)...)
+		} else {
+			result = append(result, strings.Join(p, "."))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func convertJSONPatch(paths []fieldPath, data []byte) ([]byte, error) {
+	var ops []map[string]interface{}
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		for _, key := range []string{"path", "from"} {
+			p, ok := op[key].(string)
+			if !ok {
+				continue
+			}
+			converted, err := convertJSONPointer(paths, p)
+			if err != nil {
+				return nil, err
+			}
+			op[key] = converted
+		}
+	}
+	return json.Marshal(ops)
+}
+
+func convertJSONPointer(paths []fieldPath, p string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segments[i], "~1", "/"), "~0", "~")
+	}
+	if segments[0] == "metadata" {
+		return p, nil
+	}
+
+	for _, fp := range paths {
+		if len(segments) < len(fp.duck) || !equalPaths(segments[:len(fp.duck)], fp.duck) {
+			continue
+		}
+		result := append(append([]string(nil), fp.raw...), segments[len(fp.duck):]...)
+		for i := range result {
+			result[i] = strings.ReplaceAll(strings.ReplaceAll(result[i], "~", "~0"), "/", "~1")
+		}
+		return "/" + strings.Join(result, "/"), nil
+	}
+	return "", fmt.Errorf("patch path %s is not mapped", p)
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
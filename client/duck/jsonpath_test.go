@@ -0,0 +1,237 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duck
+
+import (
+	"context"
+	"testing"
+
+	"gomodules.xyz/pointer"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var (
+	deploymentGVK = apps.SchemeGroupVersion.WithKind("Deployment")
+	databaseGVK   = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}
+	scalableGVK   = schema.GroupVersionKind{Group: "duck.test", Version: "v1", Kind: "Scalable"}
+)
+
+var scalableMapper = MustFieldMapper(clientgoscheme.Scheme, map[schema.GroupVersionKind]FieldMapping{
+	deploymentGVK: {
+		".spec.replicas":        ".spec.replicas",
+		".status.readyReplicas": ".status.readyReplicas",
+	},
+	databaseGVK: {
+		"{.spec.replicas}":        "{.spec.size}",
+		"{.status.readyReplicas}": "{.status.ready}",
+	},
+})
+
+type scalable struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              scalableSpec   `json:"spec,omitempty"`
+	Status            scalableStatus `json:"status,omitempty"`
+}
+
+type scalableSpec struct {
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+type scalableStatus struct {
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+}
+
+var (
+	_ Object      = &scalable{}
+	_ FieldMapped = &scalable{}
+)
+
+func (s *scalable) DeepCopyObject() runtime.Object {
+	out := *s
+	s.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if s.Spec.Replicas != nil {
+		out.Spec.Replicas = pointer.Int32P(*s.Spec.Replicas)
+	}
+	return &out
+}
+
+func (s *scalable) Duckify(srcRaw runtime.Object) error {
+	return scalableMapper.Duckify(srcRaw, s)
+}
+
+func (s *scalable) FieldMapper() *FieldMapper {
+	return scalableMapper
+}
+
+func newDatabase() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "db",
+				"namespace": "demo",
+			},
+			"spec": map[string]interface{}{
+				"size":    int64(3),
+				"version": "16",
+			},
+			"status": map[string]interface{}{
+				"ready": int64(2),
+			},
+		},
+	}
+	u.SetGroupVersionKind(databaseGVK)
+	return u
+}
+
+func TestFieldMapper_Duckify(t *testing.T) {
+	var s scalable
+	if err := s.Duckify(newDatabase()); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "db" || s.Spec.Replicas == nil || *s.Spec.Replicas != 3 || s.Status.ReadyReplicas != 2 {
+		t.Errorf("unexpected duck object %+v", s)
+	}
+
+	// typed objects without TypeMeta are detected using the scheme
+	deploy := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "demo"},
+		Spec:       apps.DeploymentSpec{Replicas: pointer.Int32P(5)},
+	}
+	if err := s.Duckify(deploy); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "web" || *s.Spec.Replicas != 5 {
+		t.Errorf("unexpected duck object %+v", s)
+	}
+
+	if err := s.Duckify(&apps.StatefulSet{}); err == nil {
+		t.Errorf("expected error for unmapped type")
+	}
+}
+
+func TestFieldMapper_ConvertPatch(t *testing.T) {
+	obj := &scalable{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "demo"}}
+	cases := []struct {
+		name     string
+		pt       types.PatchType
+		patch    string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "merge",
+			pt:       types.MergePatchType,
+			patch:    `{"metadata":{"labels":{"a":"b"}},"spec":{"replicas":5}}`,
+			expected: `{"metadata":{"labels":{"a":"b"}},"spec":{"size":5}}`,
+		},
+		{
+			name:     "merge null",
+			pt:       types.MergePatchType,
+			patch:    `{"spec":{"replicas":null}}`,
+			expected: `{"spec":{"size":null}}`,
+		},
+		{
+			name:  "merge unmapped",
+			pt:    types.MergePatchType,
+			patch: `{"spec":{"paused":true}}`,
+			err:   true,
+		},
+		{
+			name:     "json",
+			pt:       types.JSONPatchType,
+			patch:    `[{"op":"replace","path":"/spec/replicas","value":5},{"op":"add","path":"/metadata/labels/a","value":"b"}]`,
+			expected: `[{"op":"replace","path":"/spec/size","value":5},{"op":"add","path":"/metadata/labels/a","value":"b"}]`,
+		},
+		{
+			name:  "json unmapped",
+			pt:    types.JSONPatchType,
+			patch: `[{"op":"replace","path":"/spec/paused","value":true}]`,
+			err:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := scalableMapper.ConvertPatch(databaseGVK, obj, client.RawPatch(tc.pt, []byte(tc.patch)))
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := p.Data(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, data)
+			}
+		})
+	}
+}
+
+func TestTypedClient_PatchFieldMapped(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypeWithName(scalableGVK, &scalable{})
+
+	deploy := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "demo"},
+		Spec:       apps.DeploymentSpec{Replicas: pointer.Int32P(1)},
+	}
+	kc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deploy).Build()
+
+	raw := &apps.Deployment{}
+	raw.SetGroupVersionKind(deploymentGVK)
+	dc, err := NewClient().ForDuckType(&scalable{}).WithUnderlyingType(raw).Build(kc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s scalable
+	if err := dc.Get(ctx, client.ObjectKeyFromObject(deploy), &s); err != nil {
+		t.Fatal(err)
+	}
+	orig := s.DeepCopyObject().(*scalable)
+	s.Spec.Replicas = pointer.Int32P(4)
+	if err := dc.Patch(ctx, &s, client.MergeFrom(orig)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got apps.Deployment
+	if err := kc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatal(err)
+	}
+	if *got.Spec.Replicas != 4 {
+		t.Errorf("expected 4 replicas, got %d", *got.Spec.Replicas)
+	}
+}
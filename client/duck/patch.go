@@ -17,6 +17,7 @@ limitations under the License.
 package duck
 
 import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -39,6 +40,15 @@ func NewRawPatch(obj client.Object, patch client.Patch) (client.Patch, error) {
 	}, nil
 }
 
+// newRawPatch converts a patch of a duck object to a patch of the underlying type. If the duck
+// type implements FieldMapped, the field paths are translated using its FieldMapper.
+func newRawPatch(obj client.Object, rawGVK schema.GroupVersionKind, patch client.Patch) (client.Patch, error) {
+	if fm, ok := obj.(FieldMapped); ok {
+		return fm.FieldMapper().ConvertPatch(rawGVK, obj, patch)
+	}
+	return NewRawPatch(obj, patch)
+}
+
 func (r *RawPatch) Type() types.PatchType {
 	return r.pt
 }
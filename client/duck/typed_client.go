@@ -185,7 +185,7 @@ func (d *typedClient) Patch(ctx context.Context, obj client.Object, patch client
 		return d.c.Patch(ctx, obj, patch, opts...)
 	}
 
-	rawPatch, err := NewRawPatch(obj, patch)
+	rawPatch, err := newRawPatch(obj, d.rawGVK, patch)
 	if err != nil {
 		return err
 	}
@@ -263,7 +263,7 @@ func (sw *typedStatusWriter) Patch(ctx context.Context, obj client.Object, patch
 		return sw.client.c.Status().Patch(ctx, obj, patch, opts...)
 	}
 
-	rawPatch, err := NewRawPatch(obj, patch)
+	rawPatch, err := newRawPatch(obj, sw.client.rawGVK, patch)
 	if err != nil {
 		return err
 	}
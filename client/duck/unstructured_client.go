@@ -133,7 +133,7 @@ func (uc *unstructuredClient) Patch(ctx context.Context, obj client.Object, patc
 		return uc.c.Patch(ctx, obj, patch, opts...)
 	}
 
-	rawPatch, err := NewRawPatch(obj, patch)
+	rawPatch, err := newRawPatch(obj, uc.rawGVK, patch)
 	if err != nil {
 		return err
 	}
@@ -258,7 +258,7 @@ func (sw *unstructuredStatusWriter) Patch(ctx context.Context, obj client.Object
 		return sw.client.c.Status().Patch(ctx, obj, patch, opts...)
 	}
 
-	rawPatch, err := NewRawPatch(obj, patch)
+	rawPatch, err := newRawPatch(obj, sw.client.rawGVK, patch)
 	if err != nil {
 		return err
 	}
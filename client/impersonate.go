@@ -0,0 +1,179 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/utils/lru"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultImpersonatingPoolSize is the number of impersonating clients kept by an
+// ImpersonatingClientPool created with size 0.
+const DefaultImpersonatingPoolSize = 256
+
+// ImpersonatingClientPool caches impersonating clients created by DelegatingClient.Impersonate,
+// keyed by the user name, uid, groups and extra. Least recently used clients are evicted once
+// the pool is full.
+type ImpersonatingClientPool struct {
+	dc    *DelegatingClient
+	cache *lru.Cache
+	// mu serializes client creation, so that concurrent requests from a user create one client.
+	mu sync.Mutex
+}
+
+type impersonatingClient struct {
+	config *restclient.Config
+	client client.Client
+}
+
+// NewImpersonatingClientPool returns a pool of clients impersonating users using the
+// config of dc. If size is 0, DefaultImpersonatingPoolSize is used.
+func NewImpersonatingClientPool(dc *DelegatingClient, size int) *ImpersonatingClientPool {
+	if size <= 0 {
+		size = DefaultImpersonatingPoolSize
+	}
+	return &ImpersonatingClientPool{
+		dc:    dc,
+		cache: lru.New(size),
+	}
+}
+
+// Impersonate returns the config and client impersonating the given user.
+func (p *ImpersonatingClientPool) Impersonate(u user.Info) (*restclient.Config, client.Client, error) {
+	key := impersonationKey(u)
+	if v, ok := p.cache.Get(key); ok {
+		ic := v.(*impersonatingClient)
+		return ic.config, ic.client, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if v, ok := p.cache.Get(key); ok {
+		ic := v.(*impersonatingClient)
+		return ic.config, ic.client, nil
+	}
+	config, cc, err := p.dc.Impersonate(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.cache.Add(key, &impersonatingClient{config: config, client: cc})
+	return config, cc, nil
+}
+
+// Client returns the client impersonating the given user.
+func (p *ImpersonatingClientPool) Client(u user.Info) (client.Client, error) {
+	_, cc, err := p.Impersonate(u)
+	return cc, err
+}
+
+// ServiceAccountClient returns the client impersonating the given ServiceAccount.
+func (p *ImpersonatingClientPool) ServiceAccountClient(namespace, name string) (client.Client, error) {
+	return p.Client(serviceaccount.UserInfo(namespace, name, ""))
+}
+
+// Forget removes the client impersonating the given user from the pool.
+func (p *ImpersonatingClientPool) Forget(u user.Info) {
+	p.cache.Remove(impersonationKey(u))
+}
+
+// Len returns the number of clients in the pool.
+func (p *ImpersonatingClientPool) Len() int {
+	return p.cache.Len()
+}
+
+// CanI checks whether the given user is allowed to perform verb on the resource using a
+// SubjectAccessReview. Use an empty namespace for cluster scoped resources or to check
+// access across all namespaces.
+func (p *ImpersonatingClientPool) CanI(ctx context.Context, u user.Info, verb string, gvr schema.GroupVersionResource, namespace string) (bool, error) {
+	return CanI(ctx, p.dc, u, &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      verb,
+		Group:     gvr.Group,
+		Version:   gvr.Version,
+		Resource:  gvr.Resource,
+	})
+}
+
+// CanI checks whether the given user is allowed to access a resource using a SubjectAccessReview.
+func CanI(ctx context.Context, kc client.Client, u user.Info, attrs *authorizationv1.ResourceAttributes) (bool, error) {
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attrs,
+			User:               u.GetName(),
+			Groups:             u.GetGroups(),
+			UID:                u.GetUID(),
+		},
+	}
+	if extra := u.GetExtra(); len(extra) > 0 {
+		sar.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(extra))
+		for k, v := range extra {
+			sar.Spec.Extra[k] = v
+		}
+	}
+	if err := kc.Create(ctx, sar); err != nil {
+		return false, err
+	}
+	if sar.Status.EvaluationError != "" && !sar.Status.Allowed {
+		return false, fmt.Errorf("failed to evaluate access for user %s: %s", u.GetName(), sar.Status.EvaluationError)
+	}
+	return sar.Status.Allowed, nil
+}
+
+// impersonationKey returns a key that identifies the impersonated identity. Every name, uid,
+// group, extra key and value is quoted, so that separators in them can not cause collisions.
+func impersonationKey(u user.Info) string {
+	var sb strings.Builder
+	sb.WriteString(strconv.Quote(u.GetName()))
+	sb.WriteString(strconv.Quote(u.GetUID()))
+
+	groups := append([]string(nil), u.GetGroups()...)
+	sort.Strings(groups)
+	for _, g := range groups {
+		sb.WriteString("g")
+		sb.WriteString(strconv.Quote(g))
+	}
+
+	extra := u.GetExtra()
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := append([]string(nil), extra[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			sb.WriteString("x")
+			sb.WriteString(strconv.Quote(k))
+			sb.WriteString(strconv.Quote(v))
+		}
+	}
+	return sb.String()
+}
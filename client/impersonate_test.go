@@ -0,0 +1,178 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newDiscoveryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp any
+		switch r.URL.Path {
+		case "/api":
+			resp = metav1.APIVersions{Versions: []string{"v1"}}
+		case "/apis":
+			resp = metav1.APIGroupList{}
+		case "/api/v1":
+			resp = metav1.APIResourceList{GroupVersion: "v1"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImpersonatingClientPool(t *testing.T) {
+	srv := newDiscoveryServer(t)
+	kc, err := NewClient(&restclient.Config{Host: srv.URL}, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewImpersonatingClientPool(kc.(*DelegatingClient), 2)
+
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"a", "b"}}
+	c1, err := pool.Client(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := pool.Client(&user.DefaultInfo{Name: "alice", Groups: []string{"b", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 != c2 {
+		t.Errorf("expected the client to be reused for the same user")
+	}
+
+	config, _, err := pool.Impersonate(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Impersonate.UserName != "alice" {
+		t.Errorf("expected impersonated user alice, got %q", config.Impersonate.UserName)
+	}
+
+	if _, err := pool.ServiceAccountClient("demo", "default"); err != nil {
+		t.Fatal(err)
+	}
+	config, _, err = pool.Impersonate(&user.DefaultInfo{Name: "system:serviceaccount:demo:default", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:demo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Len() != 2 {
+		t.Errorf("expected 2 clients in the pool, got %d", pool.Len())
+	}
+	if config.Impersonate.UserName != "system:serviceaccount:demo:default" {
+		t.Errorf("unexpected impersonated service account %q", config.Impersonate.UserName)
+	}
+
+	// alice was the least recently used client
+	if _, err := pool.Client(&user.DefaultInfo{Name: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	c3, err := pool.Client(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c3 == c1 {
+		t.Errorf("expected the client for alice to be evicted")
+	}
+}
+
+func TestCanI(t *testing.T) {
+	var got authorizationv1.SubjectAccessReviewSpec
+	kc := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				sar := obj.(*authorizationv1.SubjectAccessReview)
+				got = sar.Spec
+				sar.Status.Allowed = sar.Spec.ResourceAttributes.Verb == "get"
+				return nil
+			},
+		}).
+		Build()
+
+	u := &user.DefaultInfo{Name: "alice", Groups: []string{"dev"}, Extra: map[string][]string{"scope": {"x"}}}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	for verb, expected := range map[string]bool{"get": true, "delete": false} {
+		allowed, err := CanI(context.TODO(), kc, u, &authorizationv1.ResourceAttributes{
+			Namespace: "demo",
+			Verb:      verb,
+			Group:     gvr.Group,
+			Version:   gvr.Version,
+			Resource:  gvr.Resource,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != expected {
+			t.Errorf("verb %s: expected allowed=%v, got %v", verb, expected, allowed)
+		}
+	}
+	if got.User != "alice" || len(got.Groups) != 1 || got.Extra["scope"][0] != "x" {
+		t.Errorf("unexpected SubjectAccessReview spec %+v", got)
+	}
+}
+
+func TestImpersonationKey(t *testing.T) {
+	distinct := [][2]user.Info{
+		{
+			&user.DefaultInfo{Name: "alice", Extra: map[string][]string{"a=b": {"c"}}},
+			&user.DefaultInfo{Name: "alice", Extra: map[string][]string{"a": {"b=c"}}},
+		},
+		{
+			&user.DefaultInfo{Name: "alice", Groups: []string{"a\x00g=b"}},
+			&user.DefaultInfo{Name: "alice", Groups: []string{"a", "b"}},
+		},
+		{
+			&user.DefaultInfo{Name: "alice\x00", UID: "1"},
+			&user.DefaultInfo{Name: "alice", UID: "\x001"},
+		},
+	}
+	for _, c := range distinct {
+		if impersonationKey(c[0]) == impersonationKey(c[1]) {
+			t.Errorf("expected different keys for %+v and %+v", c[0], c[1])
+		}
+	}
+
+	a := &user.DefaultInfo{Name: "alice", Groups: []string{"b", "a"}, Extra: map[string][]string{"scopes": {"y", "x"}}}
+	b := &user.DefaultInfo{Name: "alice", Groups: []string{"a", "b"}, Extra: map[string][]string{"scopes": {"x", "y"}}}
+	if impersonationKey(a) != impersonationKey(b) {
+		t.Errorf("expected the same key regardless of the order of groups and extra values")
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultTokenExpirationSeconds is the requested lifetime of the tokens issued by a TokenProvider.
+const DefaultTokenExpirationSeconds = 3600
+
+// tokenRequestTimeout bounds a token request shared by concurrent callers, including the
+// wait for a legacy token Secret to be populated.
+const tokenRequestTimeout = RetryTimeout + 30*time.Second
+
+// TokenOptions configures the tokens issued by a TokenProvider.
+type TokenOptions struct {
+	// Audiences are the intended audiences of the token. Empty means the audiences of the api server.
+	Audiences []string
+	// ExpirationSeconds is the requested lifetime of the token. The api server may issue a
+	// token with a different lifetime. Defaults to DefaultTokenExpirationSeconds.
+	ExpirationSeconds int64
+	// BoundObjectRef binds the token to the lifetime of an object, eg, a Pod or Secret.
+	BoundObjectRef *authenticationv1.BoundObjectReference
+	// RefreshBefore is the remaining lifetime at which a cached token is refreshed.
+	// Defaults to 20% of the lifetime of the token.
+	RefreshBefore time.Duration
+}
+
+// ServiceAccountToken is a token issued for a ServiceAccount.
+type ServiceAccountToken struct {
+	Token string
+	// ExpirationTimestamp is zero for tokens read from legacy token Secrets, which do not expire.
+	ExpirationTimestamp time.Time
+	// CACert is the ca.crt of a legacy token Secret.
+	CACert []byte
+
+	refreshAt time.Time
+}
+
+// TokenProvider issues ServiceAccount tokens using the TokenRequest api and caches them until they
+// are close to expiry. On clusters that do not support the TokenRequest api, it falls back to a
+// kubernetes.io/service-account-token Secret, created if needed.
+type TokenProvider struct {
+	kc   client.Client
+	opts TokenOptions
+	now  func() time.Time
+
+	// mu protects tokens; requests to the api server are made without holding it.
+	mu       sync.Mutex
+	tokens   map[client.ObjectKey]*ServiceAccountToken
+	requests singleflight.Group
+}
+
+func NewTokenProvider(kc client.Client, opts TokenOptions) *TokenProvider {
+	if opts.ExpirationSeconds <= 0 {
+		opts.ExpirationSeconds = DefaultTokenExpirationSeconds
+	}
+	return &TokenProvider{
+		kc:     kc,
+		opts:   opts,
+		now:    time.Now,
+		tokens: map[client.ObjectKey]*ServiceAccountToken{},
+	}
+}
+
+// Token returns a token for the given ServiceAccount. Cached tokens are refreshed before they expire.
+// Concurrent calls for the same ServiceAccount share a single request to the api server.
+func (p *TokenProvider) Token(ctx context.Context, sa client.ObjectKey) (*ServiceAccountToken, error) {
+	p.mu.Lock()
+	t, ok := p.tokens[sa]
+	p.mu.Unlock()
+	if ok && (t.refreshAt.IsZero() || p.now().Before(t.refreshAt)) {
+		return t, nil
+	}
+
+	// The request is shared by concurrent callers, so it must not be canceled by any one of them.
+	ch := p.requests.DoChan(sa.String(), func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRequestTimeout)
+		defer cancel()
+
+		t, err := p.requestToken(ctx, sa)
+		if isTokenRequestUnsupported(err) {
+			klog.V(3).Infof("TokenRequest api is not supported, using token secret for ServiceAccount %s", sa)
+			t, err = p.secretToken(ctx, sa)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.tokens[sa] = t
+		p.mu.Unlock()
+		return t, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.(*ServiceAccountToken), nil
+	}
+}
+
+// Forget removes the cached token for the given ServiceAccount.
+func (p *TokenProvider) Forget(sa client.ObjectKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.tokens, sa)
+}
+
+func (p *TokenProvider) requestToken(ctx context.Context, sa client.ObjectKey) (*ServiceAccountToken, error) {
+	saObj := &core.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: sa.Namespace,
+			Name:      sa.Name,
+		},
+	}
+	expirationSeconds := p.opts.ExpirationSeconds
+	tr := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         p.opts.Audiences,
+			ExpirationSeconds: &expirationSeconds,
+			BoundObjectRef:    p.opts.BoundObjectRef,
+		},
+	}
+	if err := p.kc.SubResource("token").Create(ctx, saObj, tr); err != nil {
+		return nil, err
+	}
+
+	issuedAt := p.now()
+	expiry := tr.Status.ExpirationTimestamp.Time
+	refreshBefore := p.opts.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = expiry.Sub(issuedAt) / 5
+	}
+	return &ServiceAccountToken{
+		Token:               tr.Status.Token,
+		ExpirationTimestamp: expiry,
+		refreshAt:           expiry.Add(-refreshBefore),
+	}, nil
+}
+
+func (p *TokenProvider) secretToken(ctx context.Context, sa client.ObjectKey) (*ServiceAccountToken, error) {
+	secret, err := getOrCreateServiceAccountTokenSecret(ctx, p.kc, sa)
+	if err != nil {
+		return nil, err
+	}
+	token, ok := secret.Data[core.ServiceAccountTokenKey]
+	if !ok {
+		return nil, errors.Errorf("token secret %s/%s for ServiceAccount %s is missing token", secret.Namespace, secret.Name, sa)
+	}
+	return &ServiceAccountToken{
+		Token:  string(token),
+		CACert: secret.Data[core.ServiceAccountRootCAKey],
+	}, nil
+}
+
+// isTokenRequestUnsupported returns true if the api server does not serve the token subresource of
+// ServiceAccounts, eg, on very old clusters or when the service account issuer is not configured.
+func isTokenRequestUnsupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	if kerr.IsMethodNotSupported(err) {
+		return true
+	}
+	if status, ok := err.(kerr.APIStatus); ok && kerr.IsNotFound(err) {
+		// NotFound for the ServiceAccount itself is returned as is.
+		details := status.Status().Details
+		return details == nil || details.Kind != "serviceaccounts"
+	}
+	return false
+}
+
+// RESTConfig returns a copy of cfg that authenticates as the given ServiceAccount. The token is not
+// refreshed by the returned config; call RESTConfig again after it expires.
+func (p *TokenProvider) RESTConfig(ctx context.Context, cfg *restclient.Config, sa client.ObjectKey) (*restclient.Config, error) {
+	t, err := p.Token(ctx, sa)
+	if err != nil {
+		return nil, err
+	}
+	out := restclient.AnonymousClientConfig(cfg)
+	out.BearerToken = t.Token
+	if len(out.CAData) == 0 && out.CAFile == "" && len(t.CACert) > 0 {
+		out.CAData = t.CACert
+	}
+	return out, nil
+}
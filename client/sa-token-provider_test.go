@@ -0,0 +1,277 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestTokenProvider(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Now()
+
+	var requests int
+	kc := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				if subResourceName != "token" {
+					return fmt.Errorf("unexpected subresource %s", subResourceName)
+				}
+				tr := subResource.(*authenticationv1.TokenRequest)
+				if len(tr.Spec.Audiences) != 1 || tr.Spec.Audiences[0] != "vault" {
+					return fmt.Errorf("unexpected audiences %v", tr.Spec.Audiences)
+				}
+				requests++
+				tr.Status.Token = fmt.Sprintf("token-%d", requests)
+				tr.Status.ExpirationTimestamp = metav1.NewTime(now.Add(time.Duration(*tr.Spec.ExpirationSeconds) * time.Second))
+				return nil
+			},
+		}).
+		Build()
+
+	tp := NewTokenProvider(kc, TokenOptions{Audiences: []string{"vault"}})
+	tp.now = func() time.Time { return now }
+	sa := client.ObjectKey{Namespace: "demo", Name: "default"}
+
+	for i := 0; i < 2; i++ {
+		tok, err := tp.Token(ctx, sa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.Token != "token-1" {
+			t.Errorf("expected cached token-1, got %s", tok.Token)
+		}
+	}
+
+	// 80% of the token lifetime has passed
+	now = now.Add(49 * time.Minute)
+	tok, err := tp.Token(ctx, sa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Token != "token-2" {
+		t.Errorf("expected refreshed token-2, got %s", tok.Token)
+	}
+
+	cfg, err := tp.RESTConfig(ctx, &restclient.Config{Host: "https://example.com", Username: "admin", Password: "secret"}, sa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BearerToken != "token-2" || cfg.Username != "" {
+		t.Errorf("unexpected rest config %+v", cfg)
+	}
+}
+
+func TestTokenProvider_SecretFallback(t *testing.T) {
+	ctx := context.TODO()
+
+	sa := &core.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "demo", Name: "legacy"}}
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "demo",
+			Name:        "legacy-token-abcde",
+			Annotations: map[string]string{core.ServiceAccountNameKey: "legacy"},
+		},
+		Type: core.SecretTypeServiceAccountToken,
+		Data: map[string][]byte{
+			core.ServiceAccountTokenKey:  []byte("legacy-token"),
+			core.ServiceAccountRootCAKey: []byte("ca"),
+		},
+	}
+	kc := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithObjects(sa, secret).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				return kerr.NewNotFound(schema.GroupResource{}, "")
+			},
+		}).
+		Build()
+
+	tp := NewTokenProvider(kc, TokenOptions{})
+	tok, err := tp.Token(ctx, client.ObjectKeyFromObject(sa))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Token != "legacy-token" || string(tok.CACert) != "ca" || !tok.ExpirationTimestamp.IsZero() {
+		t.Errorf("unexpected token %+v", tok)
+	}
+
+	cfg, err := tp.RESTConfig(ctx, &restclient.Config{Host: "https://example.com"}, client.ObjectKeyFromObject(sa))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cfg.CAData) != "ca" {
+		t.Errorf("expected CA from token secret, got %q", cfg.CAData)
+	}
+}
+
+func TestTokenProvider_Concurrent(t *testing.T) {
+	ctx := context.TODO()
+
+	slow := client.ObjectKey{Namespace: "demo", Name: "slow"}
+	release := make(chan struct{})
+	var requests atomic.Int32
+	kc := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				requests.Add(1)
+				if obj.GetName() == slow.Name {
+					<-release
+				}
+				tr := subResource.(*authenticationv1.TokenRequest)
+				tr.Status.Token = "token-" + obj.GetName()
+				tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+				return nil
+			},
+		}).
+		Build()
+	tp := NewTokenProvider(kc, TokenOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tok, err := tp.Token(ctx, slow); err != nil || tok.Token != "token-slow" {
+				t.Errorf("unexpected token %+v, %v", tok, err)
+			}
+		}()
+	}
+	if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		return requests.Load() > 0, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tp.Token(ctx, client.ObjectKey{Namespace: "demo", Name: "fast"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("token request for one ServiceAccount blocked by another")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected concurrent requests for the same ServiceAccount to be shared, found %d requests", n)
+	}
+}
+
+func TestTokenProvider_CanceledWaiter(t *testing.T) {
+	sa := client.ObjectKey{Namespace: "demo", Name: "default"}
+	started, release := make(chan struct{}), make(chan struct{})
+	kc := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				close(started)
+				select {
+				case <-release:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				tr := subResource.(*authenticationv1.TokenRequest)
+				tr.Status.Token = "token"
+				tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+				return nil
+			},
+		}).
+		Build()
+	tp := NewTokenProvider(kc, TokenOptions{})
+
+	// the first caller starts the shared request and gives up
+	ctx, cancel := context.WithCancel(context.TODO())
+	first := make(chan error, 1)
+	go func() {
+		_, err := tp.Token(ctx, sa)
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		tok, err := tp.Token(context.TODO(), sa)
+		if err == nil && tok.Token != "token" {
+			err = fmt.Errorf("unexpected token %s", tok.Token)
+		}
+		second <- err
+	}()
+	// give the second caller time to join the shared request
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-first; err == nil {
+		t.Error("expected canceled caller to return an error")
+	}
+	close(release)
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Errorf("expected other callers not to be affected by a canceled caller, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for token")
+	}
+}
+
+func TestTokenProvider_SecretFallbackCanceled(t *testing.T) {
+	kc := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				return kerr.NewNotFound(schema.GroupResource{}, "")
+			},
+		}).
+		Build()
+	tp := NewTokenProvider(kc, TokenOptions{})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// the ServiceAccount does not exist, so the token secret never appears
+	if _, err := tp.Token(ctx, client.ObjectKey{Namespace: "demo", Name: "missing"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if d := time.Since(start); d >= RetryTimeout {
+		t.Errorf("expected secret fallback to stop when the context is canceled, took %v", d)
+	}
+}
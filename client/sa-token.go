@@ -34,9 +34,9 @@ import (
 )
 
 // https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/#token-controller
-func getServiceAccountTokenSecret(kc client.Client, sa client.ObjectKey) (*core.Secret, error) {
+func getServiceAccountTokenSecret(ctx context.Context, kc client.Client, sa client.ObjectKey) (*core.Secret, error) {
 	var list core.SecretList
-	err := kc.List(context.TODO(), &list, client.InNamespace(sa.Namespace))
+	err := kc.List(ctx, &list, client.InNamespace(sa.Namespace))
 	if err != nil {
 		return nil, err
 	}
@@ -61,10 +61,10 @@ const (
 	RetryTimeout = 10 * time.Second
 )
 
-func tryGetServiceAccountTokenSecret(kc client.Client, sa client.ObjectKey) (secret *core.Secret, err error) {
-	err = wait.PollUntilContextTimeout(context.Background(), kutil.RetryInterval, RetryTimeout, true, func(ctx context.Context) (bool, error) {
+func tryGetServiceAccountTokenSecret(ctx context.Context, kc client.Client, sa client.ObjectKey) (secret *core.Secret, err error) {
+	err = wait.PollUntilContextTimeout(ctx, kutil.RetryInterval, RetryTimeout, true, func(ctx context.Context) (bool, error) {
 		var e2 error
-		secret, e2 = getServiceAccountTokenSecret(kc, sa)
+		secret, e2 = getServiceAccountTokenSecret(ctx, kc, sa)
 		if e2 == nil {
 			return true, nil
 		}
@@ -75,14 +75,18 @@ func tryGetServiceAccountTokenSecret(kc client.Client, sa client.ObjectKey) (sec
 }
 
 func GetServiceAccountTokenSecret(kc client.Client, sa client.ObjectKey) (*core.Secret, error) {
-	secret, err := tryGetServiceAccountTokenSecret(kc, sa)
+	return getOrCreateServiceAccountTokenSecret(context.TODO(), kc, sa)
+}
+
+func getOrCreateServiceAccountTokenSecret(ctx context.Context, kc client.Client, sa client.ObjectKey) (*core.Secret, error) {
+	secret, err := tryGetServiceAccountTokenSecret(ctx, kc, sa)
 	if err == nil {
 		klog.V(5).Infof("secret found for ServiceAccount %s", sa)
 		return secret, nil
 	}
 
 	var saObj core.ServiceAccount
-	err = kc.Get(context.TODO(), sa, &saObj)
+	err = kc.Get(ctx, sa, &saObj)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get ServiceAccount %s", sa)
 	}
@@ -94,7 +98,7 @@ func GetServiceAccountTokenSecret(kc client.Client, sa client.ObjectKey) (*core.
 			Namespace: sa.Namespace,
 		},
 	}
-	vt, err := CreateOrPatch(context.TODO(), kc, secret, func(obj client.Object, createOp bool) client.Object {
+	vt, err := CreateOrPatch(ctx, kc, secret, func(obj client.Object, createOp bool) client.Object {
 		in := obj.(*core.Secret)
 
 		in.Type = core.SecretTypeServiceAccountToken
@@ -111,5 +115,5 @@ func GetServiceAccountTokenSecret(kc client.Client, sa client.ObjectKey) (*core.
 	}
 	klog.Infof("%s Secret %s/%s", vt, secret.Namespace, secret.Name)
 
-	return tryGetServiceAccountTokenSecret(kc, sa)
+	return tryGetServiceAccountTokenSecret(ctx, kc, sa)
 }
@@ -18,16 +18,27 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	apiutil2 "kmodules.xyz/client-go/client/apiutil"
 
+	jsonpatch "github.com/evanphx/json-patch"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	restclient "k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
@@ -37,6 +48,8 @@ const listType = "List"
 type typedClient struct {
 	c        client.Client
 	cachable apiutil2.Cachable
+	// gvks are the GVKs whose served versions are detected by RefreshTypeMap.
+	gvks []schema.GroupVersionKind
 	*readerWrapper
 }
 
@@ -70,19 +83,39 @@ func (d *typedClient) RESTMapper() apimeta.RESTMapper {
 type readerWrapper struct {
 	c       client.Reader
 	scheme  *runtime.Scheme
-	typeMap map[schema.GroupVersionKind]schema.GroupVersionKind
-	mu      sync.Mutex
+	typeMap *typeMap
 }
 
 var _ client.Reader = &readerWrapper{}
 
 func (d *readerWrapper) getMappedType(gvk schema.GroupVersionKind) (schema.GroupVersionKind, bool) {
-	d.mu.Lock()
-	rawGVK, found := d.typeMap[gvk]
-	d.mu.Unlock()
+	return d.typeMap.get(gvk)
+}
+
+// typeMap maps the GVKs used by the callers to the GVKs served by the api server.
+// It is shared by the cached and the direct readers of a client, so that both see refreshes.
+type typeMap struct {
+	mu sync.RWMutex
+	m  map[schema.GroupVersionKind]schema.GroupVersionKind
+}
+
+func newTypeMap(m map[schema.GroupVersionKind]schema.GroupVersionKind) *typeMap {
+	return &typeMap{m: m}
+}
+
+func (tm *typeMap) get(gvk schema.GroupVersionKind) (schema.GroupVersionKind, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	rawGVK, found := tm.m[gvk]
 	return rawGVK, found
 }
 
+func (tm *typeMap) set(m map[schema.GroupVersionKind]schema.GroupVersionKind) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.m = m
+}
+
 func (d *readerWrapper) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
 	gvk, err := apiutil.GVKForObject(obj, d.scheme)
 	if err != nil {
@@ -178,7 +211,11 @@ func (d *typedClient) Create(ctx context.Context, obj client.Object, opts ...cli
 	if err != nil {
 		return err
 	}
-	return d.c.Create(ctx, llo, opts...)
+	err = d.c.Create(ctx, llo, opts...)
+	if err != nil {
+		return err
+	}
+	return d.Scheme().Convert(llo, obj, nil)
 }
 
 func (d *typedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
@@ -223,7 +260,11 @@ func (d *typedClient) Update(ctx context.Context, obj client.Object, opts ...cli
 	if err != nil {
 		return err
 	}
-	return d.c.Update(ctx, llo, opts...)
+	err = d.c.Update(ctx, llo, opts...)
+	if err != nil {
+		return err
+	}
+	return d.Scheme().Convert(llo, obj, nil)
 }
 
 func (d *typedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
@@ -237,6 +278,11 @@ func (d *typedClient) Patch(ctx context.Context, obj client.Object, patch client
 		return d.c.Patch(ctx, obj, patch, opts...)
 	}
 
+	rawPatch, err := d.convertPatch(ctx, gvk, rawGVK, obj, patch)
+	if err != nil {
+		return err
+	}
+
 	ll, err := d.c.Scheme().New(rawGVK)
 	if err != nil {
 		return err
@@ -244,8 +290,11 @@ func (d *typedClient) Patch(ctx context.Context, obj client.Object, patch client
 	llo := ll.(client.Object)
 	llo.SetNamespace(obj.GetNamespace())
 	llo.SetName(obj.GetName())
-	llo.SetLabels(obj.GetLabels())
-	return d.c.Patch(ctx, llo, patch, opts...)
+	err = d.c.Patch(ctx, llo, rawPatch, opts...)
+	if err != nil {
+		return err
+	}
+	return d.Scheme().Convert(llo, obj, nil)
 }
 
 func (d *typedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
@@ -302,7 +351,11 @@ func (sw *typedStatusWriter) Create(ctx context.Context, obj client.Object, subR
 	if err != nil {
 		return err
 	}
-	return sw.client.c.Status().Create(ctx, llo, subResource, opts...)
+	err = sw.client.c.Status().Create(ctx, llo, subResource, opts...)
+	if err != nil {
+		return err
+	}
+	return sw.client.Scheme().Convert(llo, obj, nil)
 }
 
 func (sw *typedStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
@@ -325,7 +378,11 @@ func (sw *typedStatusWriter) Update(ctx context.Context, obj client.Object, opts
 	if err != nil {
 		return err
 	}
-	return sw.client.c.Status().Update(ctx, llo, opts...)
+	err = sw.client.c.Status().Update(ctx, llo, opts...)
+	if err != nil {
+		return err
+	}
+	return sw.client.Scheme().Convert(llo, obj, nil)
 }
 
 func (sw *typedStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
@@ -339,6 +396,11 @@ func (sw *typedStatusWriter) Patch(ctx context.Context, obj client.Object, patch
 		return sw.client.c.Status().Patch(ctx, obj, patch, opts...)
 	}
 
+	rawPatch, err := sw.client.convertPatch(ctx, gvk, rawGVK, obj, patch)
+	if err != nil {
+		return err
+	}
+
 	ll, err := sw.client.c.Scheme().New(rawGVK)
 	if err != nil {
 		return err
@@ -346,8 +408,11 @@ func (sw *typedStatusWriter) Patch(ctx context.Context, obj client.Object, patch
 	llo := ll.(client.Object)
 	llo.SetNamespace(obj.GetNamespace())
 	llo.SetName(obj.GetName())
-	llo.SetLabels(obj.GetLabels())
-	return sw.client.c.Status().Patch(ctx, llo, patch, opts...)
+	err = sw.client.c.Status().Patch(ctx, llo, rawPatch, opts...)
+	if err != nil {
+		return err
+	}
+	return sw.client.Scheme().Convert(llo, obj, nil)
 }
 
 func (d *typedClient) SubResource(subResource string) client.SubResourceClient {
@@ -355,10 +420,7 @@ func (d *typedClient) SubResource(subResource string) client.SubResourceClient {
 }
 
 func (d *typedClient) GVK(gvk schema.GroupVersionKind) (bool, error) {
-	d.mu.Lock()
-	rawGVK, found := d.typeMap[gvk]
-	d.mu.Unlock()
-
+	rawGVK, found := d.typeMap.get(gvk)
 	if !found {
 		return d.cachable.GVK(gvk)
 	}
@@ -419,6 +481,203 @@ func BuildTypeMap(kc client.Client, gvks ...schema.GroupVersionKind) (map[schema
 	return tm, nil
 }
 
+// BuildTypeMapForCachable maps each of the given GVKs that is not served by the api server to
+// another version of the same kind that is served, according to cachable, and can be converted
+// to using the scheme of kc. Versions registered in the scheme are tried in priority order.
+func BuildTypeMapForCachable(kc client.Client, cachable apiutil2.Cachable, gvks ...schema.GroupVersionKind) (map[schema.GroupVersionKind]schema.GroupVersionKind, error) {
+	tm := map[schema.GroupVersionKind]schema.GroupVersionKind{}
+
+	for _, gvk := range gvks {
+		if _, err := cachable.GVK(gvk); err == nil {
+			continue
+		} else if !apimeta.IsNoMatchError(err) {
+			return nil, err
+		}
+
+		var found bool
+		for _, gv := range kc.Scheme().VersionsForGroupKind(gvk.GroupKind()) {
+			candidate := gv.WithKind(gvk.Kind)
+			if candidate == gvk {
+				continue
+			}
+			if _, err := cachable.GVK(candidate); err != nil {
+				continue
+			}
+			if convertible(kc.Scheme(), gvk, candidate) {
+				tm[gvk] = candidate
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("type mapping not found for %+v", gvk)
+		}
+	}
+
+	return tm, nil
+}
+
+// convertible returns true if the scheme can convert objects between the given GVKs in both directions.
+func convertible(scheme *runtime.Scheme, a, b schema.GroupVersionKind) bool {
+	in, err := scheme.New(a)
+	if err != nil {
+		return false
+	}
+	out, err := scheme.New(b)
+	if err != nil {
+		return false
+	}
+	return scheme.Convert(in, out, nil) == nil && scheme.Convert(out, in, nil) == nil
+}
+
+// TypeMapRefresher is implemented by the clients created by NewAutoConvertClient.
+type TypeMapRefresher interface {
+	// RefreshTypeMap detects the versions served by the api server again and updates the type map.
+	RefreshTypeMap() error
+}
+
+var _ TypeMapRefresher = &typedClient{}
+
+func (d *typedClient) RefreshTypeMap() error {
+	// dynamic Cachables only reload on unknown types, so removed versions would still be found
+	type ResetCachable interface {
+		Reset() error
+	}
+	if r, ok := d.cachable.(ResetCachable); ok {
+		if err := r.Reset(); err != nil {
+			return err
+		}
+	}
+	tm, err := BuildTypeMapForCachable(d.c, d.cachable, d.gvks...)
+	if err != nil {
+		return err
+	}
+	d.typeMap.set(tm)
+	return nil
+}
+
+// convertPatch converts a patch of obj, whose version is not served by the api server, to a merge
+// patch of the served version rawGVK. The patch is applied to the current object converted to the
+// version of obj and the resulting difference of the served versions is returned. Unless the patch
+// sets a resourceVersion, the resourceVersion of the current object is used to detect conflicting updates.
+func (d *typedClient) convertPatch(ctx context.Context, gvk, rawGVK schema.GroupVersionKind, obj client.Object, patch client.Patch) (client.Patch, error) {
+	scheme := d.c.Scheme()
+
+	if patch.Type() == types.ApplyPatchType {
+		ll, err := scheme.New(rawGVK)
+		if err != nil {
+			return nil, err
+		}
+		if err := scheme.Convert(obj, ll, nil); err != nil {
+			return nil, err
+		}
+		ll.GetObjectKind().SetGroupVersionKind(rawGVK)
+		data, err := json.Marshal(ll)
+		if err != nil {
+			return nil, err
+		}
+		return client.RawPatch(types.ApplyPatchType, data), nil
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := scheme.New(rawGVK)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.c.Get(ctx, client.ObjectKeyFromObject(obj), cur.(client.Object)); err != nil {
+		return nil, err
+	}
+	curHub, err := scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := scheme.Convert(cur, curHub, nil); err != nil {
+		return nil, err
+	}
+	curHubJson, err := json.Marshal(curHub)
+	if err != nil {
+		return nil, err
+	}
+
+	var modHubJson []byte
+	switch patch.Type() {
+	case types.JSONPatchType:
+		p, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return nil, err
+		}
+		modHubJson, err = p.Apply(curHubJson)
+		if err != nil {
+			return nil, err
+		}
+	case types.MergePatchType:
+		modHubJson, err = jsonpatch.MergePatch(curHubJson, data)
+		if err != nil {
+			return nil, err
+		}
+	case types.StrategicMergePatchType:
+		modHubJson, err = strategicpatch.StrategicMergePatch(curHubJson, data, curHub)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %s", patch.Type())
+	}
+
+	modHub, err := scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(modHubJson, modHub); err != nil {
+		return nil, err
+	}
+	mod, err := scheme.New(rawGVK)
+	if err != nil {
+		return nil, err
+	}
+	if err := scheme.Convert(modHub, mod, nil); err != nil {
+		return nil, err
+	}
+
+	curJson, err := json.Marshal(cur)
+	if err != nil {
+		return nil, err
+	}
+	modJson, err := json.Marshal(mod)
+	if err != nil {
+		return nil, err
+	}
+	rawData, err := jsonpatch.CreateMergePatch(curJson, modJson)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawPatch map[string]interface{}
+	if err := json.Unmarshal(rawData, &rawPatch); err != nil {
+		return nil, err
+	}
+	// keep the resourceVersion set by an optimistic lock patch
+	if _, found, _ := unstructured.NestedString(rawPatch, "metadata", "resourceVersion"); !found {
+		if err := unstructured.SetNestedField(rawPatch, cur.(client.Object).GetResourceVersion(), "metadata", "resourceVersion"); err != nil {
+			return nil, err
+		}
+	}
+	rawData, err = json.Marshal(rawPatch)
+	if err != nil {
+		return nil, err
+	}
+	return client.RawPatch(types.MergePatchType, rawData), nil
+}
+
+// NewAutoConvertClient returns a client that reads and writes the given GVKs using another version
+// served by the api server, if they are not served. Objects are converted using the conversions
+// registered in the scheme, eg, by kmodules.xyz/client-go/client/adaptive.AddToScheme . Call
+// RefreshTypeMap, eg, using RefreshTypeMapOnCRDChange, after the versions served by the api
+// server change.
 func NewAutoConvertClient(gvks ...schema.GroupVersionKind) client.NewClientFunc {
 	return func(config *restclient.Config, options client.Options) (client.Client, error) {
 		c, err := client.New(config, options)
@@ -429,13 +688,15 @@ func NewAutoConvertClient(gvks ...schema.GroupVersionKind) client.NewClientFunc
 		if err != nil {
 			return nil, err
 		}
-		tm, err := BuildTypeMap(c, gvks...)
+		m, err := BuildTypeMapForCachable(c, cachable, gvks...)
 		if err != nil {
 			return nil, err
 		}
+		tm := newTypeMap(m)
 		tc := &typedClient{
 			c:        c,
 			cachable: cachable,
+			gvks:     gvks,
 			readerWrapper: &readerWrapper{
 				c:       c,
 				scheme:  c.Scheme(),
@@ -459,3 +720,94 @@ func NewAutoConvertClient(gvks ...schema.GroupVersionKind) client.NewClientFunc
 		return NewDelegatingClient(co)
 	}
 }
+
+// typeMapRefreshDelay is the time to wait for further CRD changes before the type map is refreshed.
+var typeMapRefreshDelay = time.Second
+
+// RefreshTypeMapOnCRDChange refreshes the type map of c, a client created by NewAutoConvertClient,
+// whenever a CustomResourceDefinition changes. Changes are batched, so that installing many CRDs
+// at once only refreshes the type map once. The CustomResourceDefinition type must be registered
+// in the scheme used by informers.
+func RefreshTypeMapOnCRDChange(ctx context.Context, c client.Client, informers cache.Informers) error {
+	if dc, ok := c.(*DelegatingClient); ok {
+		c, _ = dc.Writer.(client.Client)
+	}
+	r, ok := c.(TypeMapRefresher)
+	if !ok {
+		return fmt.Errorf("%T does not support refreshing type map", c)
+	}
+	informer, err := informers.GetInformer(ctx, &crdv1.CustomResourceDefinition{})
+	if err != nil {
+		return err
+	}
+	_, err = informer.AddEventHandler(typeMapRefreshHandler(ctx, r))
+	return err
+}
+
+// typeMapRefreshHandler returns an event handler that refreshes the type map of r once no
+// CRD has changed for typeMapRefreshDelay. CRDs of the initial list are ignored, since they
+// were already discovered when the type map was built.
+func typeMapRefreshHandler(ctx context.Context, r TypeMapRefresher) toolscache.ResourceEventHandler {
+	trigger := make(chan struct{}, 1)
+	enqueue := func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-trigger:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(typeMapRefreshDelay):
+			}
+			// changes received while waiting are covered by this refresh
+			select {
+			case <-trigger:
+			default:
+			}
+			if err := r.RefreshTypeMap(); err != nil {
+				klog.Errorf("failed to refresh type map: %v", err)
+			}
+		}
+	}()
+
+	return toolscache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				enqueue()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, ok1 := oldObj.(*crdv1.CustomResourceDefinition)
+			n, ok2 := newObj.(*crdv1.CustomResourceDefinition)
+			// a new CRD is only served once it is established, which may be after the
+			// refresh triggered by its creation
+			if ok1 && ok2 && o.Generation == n.Generation &&
+				equality.Semantic.DeepEqual(o.Status.StoredVersions, n.Status.StoredVersions) &&
+				equality.Semantic.DeepEqual(o.Status.AcceptedNames, n.Status.AcceptedNames) &&
+				crdConditionStatus(o, crdv1.Established) == crdConditionStatus(n, crdv1.Established) {
+				return
+			}
+			enqueue()
+		},
+		DeleteFunc: func(obj interface{}) {
+			enqueue()
+		},
+	}
+}
+
+func crdConditionStatus(crd *crdv1.CustomResourceDefinition, t crdv1.CustomResourceDefinitionConditionType) crdv1.ConditionStatus {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == t {
+			return c.Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,289 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apiutil2 "kmodules.xyz/client-go/client/apiutil"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type servedVersions map[schema.GroupVersionKind]bool
+
+func (s servedVersions) GVK(gvk schema.GroupVersionKind) (bool, error) {
+	if !s[gvk] {
+		return false, &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+	}
+	return true, nil
+}
+
+func (s servedVersions) GVR(gvr schema.GroupVersionResource) (bool, error) {
+	return false, &meta.NoResourceMatchError{PartialResource: gvr}
+}
+
+func addCronJobConversions(s *runtime.Scheme) error {
+	convert := func(a, b interface{}, _ conversion.Scope) error {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, b)
+	}
+	if err := s.AddConversionFunc((*batchv1.CronJob)(nil), (*batchv1beta1.CronJob)(nil), convert); err != nil {
+		return err
+	}
+	return s.AddConversionFunc((*batchv1beta1.CronJob)(nil), (*batchv1.CronJob)(nil), convert)
+}
+
+func TestAutoConvertClient(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := addCronJobConversions(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	hubGVK := batchv1.SchemeGroupVersion.WithKind("CronJob")
+	spokeGVK := batchv1beta1.SchemeGroupVersion.WithKind("CronJob")
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(spokeGVK, meta.RESTScopeNamespace)
+	kc := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build()
+
+	served := servedVersions{spokeGVK: true}
+	tm, err := BuildTypeMapForCachable(kc, served, hubGVK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm[hubGVK] != spokeGVK {
+		t.Fatalf("expected %v to be mapped to %v, got %v", hubGVK, spokeGVK, tm)
+	}
+	tc := &typedClient{
+		c:        kc,
+		cachable: served,
+		gvks:     []schema.GroupVersionKind{hubGVK},
+		readerWrapper: &readerWrapper{
+			c:       kc,
+			scheme:  scheme,
+			typeMap: newTypeMap(tm),
+		},
+	}
+
+	cj := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "demo"},
+		Spec:       batchv1.CronJobSpec{Schedule: "@daily"},
+	}
+	if err := tc.Create(ctx, cj); err != nil {
+		t.Fatal(err)
+	}
+	if cj.ResourceVersion == "" {
+		t.Errorf("expected resourceVersion to be set after create")
+	}
+
+	orig := cj.DeepCopy()
+	cj.Spec.Schedule = "@hourly"
+	if err := tc.Patch(ctx, cj, client.MergeFrom(orig)); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored batchv1beta1.CronJob
+	if err := kc.Get(ctx, client.ObjectKeyFromObject(cj), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Spec.Schedule != "@hourly" {
+		t.Errorf("expected stored schedule @hourly, got %s", stored.Spec.Schedule)
+	}
+
+	// a stale patch is rejected
+	stale := orig.DeepCopy()
+	stale.Spec.Suspend = new(bool)
+	if err := tc.Patch(ctx, stale, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err == nil {
+		t.Errorf("expected conflict for patch with stale resourceVersion")
+	}
+
+	var got batchv1.CronJob
+	if err := tc.Get(ctx, client.ObjectKeyFromObject(cj), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.Schedule != "@hourly" {
+		t.Errorf("expected schedule @hourly, got %s", got.Spec.Schedule)
+	}
+
+	// the cluster is upgraded
+	served[hubGVK] = true
+	if err := tc.RefreshTypeMap(); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := tc.getMappedType(hubGVK); found {
+		t.Errorf("expected %v not to be mapped after refresh", hubGVK)
+	}
+}
+
+func TestRefreshTypeMap_RemovedVersion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := addCronJobConversions(scheme); err != nil {
+		t.Fatal(err)
+	}
+	hubGVK := batchv1.SchemeGroupVersion.WithKind("CronJob")
+	kc := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	cronJobs := func(gv string) *metav1.APIResourceList {
+		return &metav1.APIResourceList{
+			GroupVersion: gv,
+			APIResources: []metav1.APIResource{
+				{Name: "cronjobs", Kind: "CronJob", Namespaced: true, Verbs: []string{"get", "list", "watch"}},
+			},
+		}
+	}
+	disc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+		Resources: []*metav1.APIResourceList{cronJobs("batch/v1"), cronJobs("batch/v1beta1")},
+	}}
+	cachable, err := apiutil2.NewDynamicCachable(&restclient.Config{Host: "https://example.com"}, apiutil2.WithCustomCachable(func() (apiutil2.Cachable, error) {
+		return apiutil2.NewCachable(disc)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm, err := BuildTypeMapForCachable(kc, cachable, hubGVK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := &typedClient{
+		c:        kc,
+		cachable: cachable,
+		gvks:     []schema.GroupVersionKind{hubGVK},
+		readerWrapper: &readerWrapper{
+			c:       kc,
+			scheme:  scheme,
+			typeMap: newTypeMap(tm),
+		},
+	}
+	if _, found := tc.getMappedType(hubGVK); found {
+		t.Fatalf("expected %v not to be mapped while served", hubGVK)
+	}
+
+	// the served version is removed, eg, the CRD drops it
+	disc.Resources = disc.Resources[1:]
+	if err := tc.RefreshTypeMap(); err != nil {
+		t.Fatal(err)
+	}
+	if rawGVK, found := tc.getMappedType(hubGVK); !found || rawGVK.Version != "v1beta1" {
+		t.Errorf("expected %v to be mapped to v1beta1 after refresh, got %v", hubGVK, rawGVK)
+	}
+}
+
+type countingRefresher struct {
+	calls atomic.Int32
+}
+
+func (r *countingRefresher) RefreshTypeMap() error {
+	r.calls.Add(1)
+	return nil
+}
+
+func TestTypeMapRefreshHandler(t *testing.T) {
+	defer func(d time.Duration) { typeMapRefreshDelay = d }(typeMapRefreshDelay)
+	typeMapRefreshDelay = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	r := &countingRefresher{}
+	h := typeMapRefreshHandler(ctx, r)
+
+	crd := &crdv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "foos.example.com", Generation: 1}}
+	for i := 0; i < 10; i++ {
+		h.OnAdd(crd, true)
+	}
+	status := crd.DeepCopy()
+	status.Status.Conditions = []crdv1.CustomResourceDefinitionCondition{{Type: crdv1.NonStructuralSchema, Status: crdv1.ConditionTrue}}
+	h.OnUpdate(crd, status)
+	time.Sleep(4 * typeMapRefreshDelay)
+	if n := r.calls.Load(); n != 0 {
+		t.Fatalf("expected initial list and status updates to be ignored, found %d refreshes", n)
+	}
+
+	for i := 0; i < 10; i++ {
+		h.OnAdd(crd, false)
+	}
+	if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		return r.calls.Load() > 0, nil
+	}); err != nil {
+		t.Fatal("timed out waiting for type map refresh")
+	}
+	time.Sleep(4 * typeMapRefreshDelay)
+	if n := r.calls.Load(); n != 1 {
+		t.Errorf("expected changes to be batched into 1 refresh, found %d", n)
+	}
+}
+
+func TestTypeMapRefreshHandler_Established(t *testing.T) {
+	defer func(d time.Duration) { typeMapRefreshDelay = d }(typeMapRefreshDelay)
+	typeMapRefreshDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	r := &countingRefresher{}
+	h := typeMapRefreshHandler(ctx, r)
+	waitForRefreshes := func(n int32) {
+		t.Helper()
+		if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+			return r.calls.Load() >= n, nil
+		}); err != nil {
+			t.Fatalf("timed out waiting for %d type map refreshes, found %d", n, r.calls.Load())
+		}
+	}
+
+	// the CRD is refreshed before it is established
+	crd := &crdv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "foos.example.com", Generation: 1}}
+	h.OnAdd(crd, false)
+	waitForRefreshes(1)
+
+	accepted := crd.DeepCopy()
+	accepted.Status.AcceptedNames = crdv1.CustomResourceDefinitionNames{Plural: "foos", Kind: "Foo"}
+	h.OnUpdate(crd, accepted)
+	waitForRefreshes(2)
+
+	established := accepted.DeepCopy()
+	established.Status.Conditions = []crdv1.CustomResourceDefinitionCondition{{Type: crdv1.Established, Status: crdv1.ConditionTrue}}
+	h.OnUpdate(accepted, established)
+	waitForRefreshes(3)
+}
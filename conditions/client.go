@@ -0,0 +1,129 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// UpdateStatusConditions computes the condition changes between before and after and applies them
+// to the latest version of the object read from the API server, using a three-way merge. The
+// result is written using the status subresource with the resourceVersion of the latest object
+// as precondition; the whole operation is retried on API conflicts.
+//
+// If the conditions have been changed by another controller in a way that is not compatible with
+// the patch, a *ConflictError listing all the conflicting condition types is returned. Use
+// WithOwnedConditions or WithForceOverwrite to resolve conflicts in favor of after.
+//
+// On success, the conditions and resourceVersion of after are updated to match the server. If
+// after was wrapped using WithObservers, its observers are notified of the resulting transitions.
+func UpdateStatusConditions(ctx context.Context, c client.Client, before, after Setter, options ...ApplyOption) error {
+	patch := NewPatch(before, after)
+	if patch.IsZero() {
+		return nil
+	}
+
+	key := client.ObjectKeyFromObject(after)
+	var latest Setter
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, l, err := newLatest(c.Scheme(), after)
+		if err != nil {
+			return err
+		}
+		latest = l
+		if err := c.Get(ctx, key, obj); err != nil {
+			return err
+		}
+		if err := patch.Apply(latest, options...); err != nil {
+			if IsConflict(err) {
+				return findConflicts(c.Scheme(), patch, latest, options...)
+			}
+			return err
+		}
+		return c.Status().Update(ctx, obj)
+	})
+	if err != nil {
+		var ce *ConflictError
+		if errors.As(err, &ce) {
+			return err
+		}
+		return fmt.Errorf("failed to update conditions of %s: %w", key, err)
+	}
+
+	after.SetConditions(latest.GetConditions())
+	after.SetResourceVersion(latest.GetResourceVersion())
+	return nil
+}
+
+// newLatest returns an empty object of the same type as obj to read the latest
+// version into and the Setter used to access its conditions. An empty object is
+// used so that fields omitted by the server are not carried over from obj.
+func newLatest(scheme *runtime.Scheme, obj Setter) (client.Object, Setter, error) {
+	switch w := obj.(type) {
+	case *observedSetter:
+		// observers are notified once the conditions are copied back to obj
+		return newLatest(scheme, w.Setter)
+	case *unstructuredWrapper:
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(w.GroupVersionKind())
+		return u, &unstructuredWrapper{Unstructured: u, opts: w.opts}, nil
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+	ro, err := scheme.New(gvk)
+	if err != nil {
+		return nil, nil, err
+	}
+	latest, ok := ro.(Setter)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement conditions.Setter", ro)
+	}
+	latest.GetObjectKind().SetGroupVersionKind(gvk)
+	return latest, latest, nil
+}
+
+// findConflicts applies every operation of the patch separately to a copy of latest
+// and collects all the conflicts.
+func findConflicts(scheme *runtime.Scheme, patch Patch, latest Setter, options ...ApplyOption) error {
+	out := &ConflictError{}
+	for _, op := range patch {
+		_, tmp, err := newLatest(scheme, latest)
+		if err != nil {
+			return err
+		}
+		tmp.SetConditions(latest.GetConditions().DeepCopy())
+		err = Patch{op}.Apply(tmp, options...)
+
+		var ce *ConflictError
+		if errors.As(err, &ce) {
+			out.Conditions = append(out.Conditions, ce.Conditions...)
+			out.messages = append(out.messages, ce.messages...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,188 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestUpdateStatusConditions(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	obj := newUnstructuredFoo()
+	obj.SetNamespace("default")
+	c := fake.NewClientBuilder().
+		WithScheme(runtime.NewScheme()).
+		WithObjects(obj).
+		WithStatusSubresource(obj).
+		Build()
+
+	read := func() *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(obj.GroupVersionKind())
+		g.Expect(c.Get(ctx, client.ObjectKeyFromObject(obj), u)).To(Succeed())
+		return u
+	}
+
+	// controller A reads the object and marks Progressing False
+	before := read()
+	after := before.DeepCopy()
+	MarkFalse(UnstructuredSetter(after), "Progressing", "Stalled", kmapi.ConditionSeverityWarning, "")
+
+	// in the meantime controller B changes Available and adds Progressing
+	other := read()
+	MarkFalse(UnstructuredSetter(other), "Available", "Down", kmapi.ConditionSeverityError, "")
+	MarkTrue(UnstructuredSetter(other), "Progressing")
+	g.Expect(c.Status().Update(ctx, other)).To(Succeed())
+
+	err := UpdateStatusConditions(ctx, c, UnstructuredSetter(before), UnstructuredSetter(after))
+	g.Expect(IsConflict(err)).To(BeTrue())
+	var ce *ConflictError
+	g.Expect(errors.As(err, &ce)).To(BeTrue())
+	g.Expect(ce.Conditions).To(ConsistOf(kmapi.ConditionType("Progressing")))
+
+	// Owning the condition resolves the conflict without touching the conditions of controller B.
+	setter := UnstructuredSetter(after)
+	g.Expect(UpdateStatusConditions(ctx, c, UnstructuredSetter(before), setter, WithOwnedConditions("Progressing"))).To(Succeed())
+	g.Expect(setter.GetResourceVersion()).To(Equal(read().GetResourceVersion()))
+
+	latest := UnstructuredGetter(read())
+	g.Expect(IsFalse(latest, "Progressing")).To(BeTrue())
+	g.Expect(GetReason(latest, "Available")).To(Equal("Down"))
+}
+
+func TestUpdateStatusConditions_WithObservers(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	obj := newUnstructuredFoo()
+	obj.SetNamespace("default")
+	c := fake.NewClientBuilder().
+		WithScheme(runtime.NewScheme()).
+		WithObjects(obj).
+		WithStatusSubresource(obj).
+		Build()
+
+	// another controller marks Available False
+	other := obj.DeepCopy()
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(obj), other)).To(Succeed())
+	MarkFalse(UnstructuredSetter(other), "Available", "Down", kmapi.ConditionSeverityError, "")
+	g.Expect(c.Status().Update(ctx, other)).To(Succeed())
+
+	var transitions []Transition
+	after := WithObservers(UnstructuredSetter(obj.DeepCopy()), TransitionObserverFunc(func(_ Getter, t Transition) {
+		transitions = append(transitions, t)
+	}))
+	MarkTrue(after, "Progressing")
+	g.Expect(transitions).To(HaveLen(1))
+
+	g.Expect(UpdateStatusConditions(ctx, c, UnstructuredSetter(obj), after)).To(Succeed())
+	g.Expect(IsFalse(after, "Available")).To(BeTrue())
+	g.Expect(transitions).To(HaveLen(2))
+	g.Expect(transitions[1].Type).To(Equal(kmapi.ConditionType("Available")))
+}
+
+var barGVK = schema.GroupVersionKind{Group: "test.kmodules.xyz", Version: "v1", Kind: "Bar"}
+
+type BarStatus struct {
+	Phase      string           `json:"phase,omitempty"`
+	Conditions kmapi.Conditions `json:"conditions,omitempty"`
+}
+
+type Bar struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Status            BarStatus `json:"status,omitempty"`
+}
+
+func (b *Bar) GetConditions() kmapi.Conditions {
+	return b.Status.Conditions
+}
+
+func (b *Bar) SetConditions(conditions kmapi.Conditions) {
+	b.Status.Conditions = conditions
+}
+
+func (b *Bar) DeepCopyObject() runtime.Object {
+	out := *b
+	b.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Status.Conditions = b.Status.Conditions.DeepCopy()
+	return &out
+}
+
+func TestUpdateStatusConditions_StaleStatus(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(barGVK, &Bar{})
+	metav1.AddToGroupVersion(scheme, barGVK.GroupVersion())
+
+	bar := &Bar{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bar"}}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(bar).
+		WithStatusSubresource(bar).
+		WithInterceptorFuncs(interceptor.Funcs{
+			// decode into obj without clearing it first, like the json decoder of a real client
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				var latest Bar
+				if err := c.Get(ctx, key, &latest, opts...); err != nil {
+					return err
+				}
+				data, err := json.Marshal(&latest)
+				if err != nil {
+					return err
+				}
+				return json.Unmarshal(data, obj)
+			},
+		}).
+		Build()
+
+	var before Bar
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(bar), &before)).To(Succeed())
+	after := before.DeepCopyObject().(*Bar)
+	after.Status.Phase = "Unsaved"
+
+	var transitions int
+	setter := WithObservers(after, TransitionObserverFunc(func(_ Getter, _ Transition) {
+		transitions++
+	}))
+	MarkTrue(setter, "Ready")
+	g.Expect(UpdateStatusConditions(ctx, c, &before, setter)).To(Succeed())
+	g.Expect(transitions).To(Equal(1))
+
+	var latest Bar
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(bar), &latest)).To(Succeed())
+	g.Expect(IsTrue(&latest, "Ready")).To(BeTrue())
+	g.Expect(latest.Status.Phase).To(BeEmpty())
+}
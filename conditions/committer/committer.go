@@ -19,12 +19,18 @@ package committer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 // Patcher is just the Patch API with a generic to keep use sites type safe
@@ -37,35 +43,209 @@ type StatusGetter[St any] interface {
 	client.Object
 }
 
-func NewStatusCommitter[R any, St any](patcher Patcher) func(context.Context, StatusGetter[St], StatusGetter[St]) error {
+// NewStatusCommitter returns a function that commits the changes in the status of obj
+// compared to old. By default, the status is patched using a merge patch on the status
+// subresource and nothing is written if the status is unchanged. Use options to commit
+// finalizers and annotations alongside the status, to use optimistic locking or server-side
+// apply and to collect commit stats.
+func NewStatusCommitter[R any, St any](patcher Patcher, opts ...Option) func(context.Context, StatusGetter[St], StatusGetter[St]) error {
 	focusType := fmt.Sprintf("%T", *new(R))
-	return func(ctx context.Context, old, obj StatusGetter[St]) error {
-		logger := klog.FromContext(ctx)
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(ctx context.Context, old, obj StatusGetter[St]) (err error) {
+		if o.err != nil {
+			o.stats.record(false, o.err)
+			return fmt.Errorf("failed to commit %s %s/%s: %w", focusType, obj.GetNamespace(), obj.GetName(), o.err)
+		}
 		statusChanged := !equality.Semantic.DeepEqual(old.GetStatus(), obj.GetStatus())
-		if !statusChanged {
+		metaChanged := o.objectPatcher != nil &&
+			(o.finalizers && !equality.Semantic.DeepEqual(old.GetFinalizers(), obj.GetFinalizers()) ||
+				o.annotations && !equality.Semantic.DeepEqual(old.GetAnnotations(), obj.GetAnnotations()))
+		if !statusChanged && !metaChanged {
+			o.stats.record(false, nil)
 			return nil
 		}
+		defer func() {
+			o.stats.record(true, err)
+		}()
 
-		ns := old.GetNamespace()
-		name := old.GetName()
+		// base tracks the resourceVersion of the last write, so that optimistic
+		// locks are not broken by our own writes.
+		base := old.DeepCopyObject().(StatusGetter[St])
 
-		oldData, err := json.Marshal(old.GetStatus())
-		if err != nil {
-			return fmt.Errorf("failed to Marshal old data for %s %s/%s: %w", focusType, ns, name, err)
+		// Finalizers are added before the status is committed and removed after,
+		// since removing the last finalizer may delete the object.
+		metaFirst := metaChanged && !removesFinalizer(old, obj, o)
+		if metaFirst {
+			if err := commitMetadata(ctx, focusType, base, obj, o); err != nil {
+				return err
+			}
 		}
+		if statusChanged {
+			// The status patch response overwrites the metadata of obj,
+			// so the pending metadata changes are restored afterwards.
+			finalizers, annotations := obj.GetFinalizers(), obj.GetAnnotations()
+			if err := commitStatus[St](ctx, focusType, patcher, base, obj, o); err != nil {
+				return err
+			}
+			if metaChanged && !metaFirst {
+				obj.SetFinalizers(finalizers)
+				obj.SetAnnotations(annotations)
+			}
+		}
+		if metaChanged && !metaFirst {
+			if err := commitMetadata(ctx, focusType, base, obj, o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func commitStatus[St any](ctx context.Context, focusType string, patcher Patcher, base, obj StatusGetter[St], o *options) error {
+	logger := klog.FromContext(ctx)
+	ns := obj.GetNamespace()
+	name := obj.GetName()
 
-		newData, err := json.Marshal(obj.GetStatus())
+	if o.serverSideApply {
+		u, err := statusApplyConfiguration(obj, o.scheme)
 		if err != nil {
-			return fmt.Errorf("failed to Marshal new data for %s %s/%s: %w", focusType, ns, name, err)
+			return fmt.Errorf("failed to build apply configuration for %s %s/%s: %w", focusType, ns, name, err)
+		}
+		if o.optimisticLock {
+			u.SetResourceVersion(base.GetResourceVersion())
+		}
+		applyOpts := []client.SubResourcePatchOption{client.FieldOwner(o.fieldOwner)}
+		if o.force {
+			applyOpts = append(applyOpts, client.ForceOwnership)
+		}
+		logger.V(3).Info(fmt.Sprintf("applying status of %s", focusType), "fieldOwner", o.fieldOwner)
+		if err := patcher.Patch(ctx, u, client.Apply, applyOpts...); err != nil {
+			return err
 		}
+		obj.SetResourceVersion(u.GetResourceVersion())
+		base.SetResourceVersion(u.GetResourceVersion())
+		return nil
+	}
 
-		patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
-		if err != nil {
-			return fmt.Errorf("failed to create patch for %s %s/%s: %w", focusType, ns, name, err)
+	oldData, err := json.Marshal(base.GetStatus())
+	if err != nil {
+		return fmt.Errorf("failed to Marshal old data for %s %s/%s: %w", focusType, ns, name, err)
+	}
+
+	newData, err := json.Marshal(obj.GetStatus())
+	if err != nil {
+		return fmt.Errorf("failed to Marshal new data for %s %s/%s: %w", focusType, ns, name, err)
+	}
+
+	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return fmt.Errorf("failed to create patch for %s %s/%s: %w", focusType, ns, name, err)
+	}
+
+	logger.V(3).Info(fmt.Sprintf("patching %s", focusType), "patch", string(patchBytes))
+	patch := client.MergeFrom(base)
+	if o.optimisticLock {
+		patch = client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})
+	}
+	if err := patcher.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+	base.SetResourceVersion(obj.GetResourceVersion())
+	return nil
+}
+
+// commitMetadata patches the finalizers and annotations of the object. The patch is sent
+// using a copy of obj, so that the response does not overwrite the pending status of obj.
+func commitMetadata(ctx context.Context, focusType string, base, obj client.Object, o *options) error {
+	ns := obj.GetNamespace()
+	name := obj.GetName()
+
+	oldMeta := map[string]interface{}{}
+	newMeta := map[string]interface{}{}
+	if o.finalizers {
+		oldMeta["finalizers"] = base.GetFinalizers()
+		newMeta["finalizers"] = obj.GetFinalizers()
+	}
+	if o.annotations {
+		oldMeta["annotations"] = base.GetAnnotations()
+		newMeta["annotations"] = obj.GetAnnotations()
+	}
+	oldData, err := json.Marshal(map[string]interface{}{"metadata": oldMeta})
+	if err != nil {
+		return fmt.Errorf("failed to Marshal old metadata for %s %s/%s: %w", focusType, ns, name, err)
+	}
+	newData, err := json.Marshal(map[string]interface{}{"metadata": newMeta})
+	if err != nil {
+		return fmt.Errorf("failed to Marshal new metadata for %s %s/%s: %w", focusType, ns, name, err)
+	}
+	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return fmt.Errorf("failed to create metadata patch for %s %s/%s: %w", focusType, ns, name, err)
+	}
+	if o.optimisticLock {
+		var patch map[string]interface{}
+		if err := json.Unmarshal(patchBytes, &patch); err != nil {
+			return err
+		}
+		md, _ := patch["metadata"].(map[string]interface{})
+		if md == nil {
+			md = map[string]interface{}{}
+			patch["metadata"] = md
+		}
+		md["resourceVersion"] = base.GetResourceVersion()
+		if patchBytes, err = json.Marshal(patch); err != nil {
+			return err
+		}
+	}
+
+	klog.FromContext(ctx).V(3).Info(fmt.Sprintf("patching metadata of %s", focusType), "patch", string(patchBytes))
+	cp := obj.DeepCopyObject().(client.Object)
+	if err := o.objectPatcher.Patch(ctx, cp, client.RawPatch(types.MergePatchType, patchBytes)); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(cp.GetResourceVersion())
+	base.SetResourceVersion(cp.GetResourceVersion())
+	return nil
+}
+
+func removesFinalizer(old, obj client.Object, o *options) bool {
+	if !o.finalizers {
+		return false
+	}
+	finalizers := sets.New[string](obj.GetFinalizers()...)
+	for _, f := range old.GetFinalizers() {
+		if !finalizers.Has(f) {
+			return true
 		}
+	}
+	return false
+}
 
-		logger.V(3).Info(fmt.Sprintf("patching %s", focusType), "patch", string(patchBytes))
-		patch := client.MergeFrom(old)
-		return patcher.Patch(ctx, obj, patch)
+// statusApplyConfiguration returns an apply configuration with the identity and status of obj.
+func statusApplyConfiguration(obj client.Object, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		if scheme == nil {
+			return nil, errors.New("apiVersion and kind must be set for server-side apply")
+		}
+		var err error
+		if gvk, err = apiutil.GVKForObject(obj, scheme); err != nil {
+			return nil, err
+		}
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	u.SetGroupVersionKind(gvk)
+	u.SetNamespace(obj.GetNamespace())
+	u.SetName(obj.GetName())
+	if status, ok := content["status"]; ok {
+		u.Object["status"] = status
 	}
+	return u, nil
 }
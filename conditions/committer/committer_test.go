@@ -0,0 +1,258 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package committer
+
+import (
+	"context"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var fooGVK = schema.GroupVersionKind{Group: "test.kmodules.xyz", Version: "v1", Kind: "Foo"}
+
+type FooStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+type Foo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Status            FooStatus `json:"status,omitempty"`
+}
+
+func (f *Foo) GetStatus() FooStatus {
+	return f.Status
+}
+
+func (f *Foo) DeepCopyObject() runtime.Object {
+	out := *f
+	f.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(fooGVK, &Foo{})
+	metav1.AddToGroupVersion(scheme, fooGVK.GroupVersion())
+	return scheme
+}
+
+func newFoo() *Foo {
+	return &Foo{
+		ObjectMeta: metav1.ObjectMeta{Namespace: core.NamespaceDefault, Name: "foo"},
+		Status:     FooStatus{Phase: "Pending"},
+	}
+}
+
+// newClient returns a fake client that records the order of metadata and status patches.
+func newClient(t *testing.T, calls *[]string) client.Client {
+	t.Helper()
+	return fake.NewClientBuilder().
+		WithScheme(newScheme()).
+		WithObjects(newFoo()).
+		WithStatusSubresource(&Foo{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				*calls = append(*calls, "metadata")
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				*calls = append(*calls, subResourceName)
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+}
+
+func get(t *testing.T, c client.Client) *Foo {
+	t.Helper()
+	var obj Foo
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: core.NamespaceDefault, Name: "foo"}, &obj); err != nil {
+		t.Fatal(err)
+	}
+	return &obj
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStatusCommitter_Finalizers(t *testing.T) {
+	ctx := context.TODO()
+	var calls []string
+	c := newClient(t, &calls)
+	commit := NewStatusCommitter[Foo, FooStatus](c.Status(), WithFinalizers(c))
+
+	// adding a finalizer is committed before the status
+	old := get(t, c)
+	obj := old.DeepCopyObject().(*Foo)
+	obj.Finalizers = []string{"test.kmodules.xyz/cleanup"}
+	obj.Status.Phase = "Running"
+	if err := commit(ctx, old, obj); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(calls, []string{"metadata", "status"}) {
+		t.Errorf("expected metadata to be patched before status, got %v", calls)
+	}
+	latest := get(t, c)
+	if len(latest.Finalizers) != 1 || latest.Status.Phase != "Running" {
+		t.Errorf("unexpected object %+v", latest)
+	}
+
+	// removing a finalizer is committed after the status
+	calls = nil
+	old = latest
+	obj = old.DeepCopyObject().(*Foo)
+	obj.Finalizers = nil
+	obj.Status.Phase = "Terminating"
+	if err := commit(ctx, old, obj); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(calls, []string{"status", "metadata"}) {
+		t.Errorf("expected status to be patched before metadata, got %v", calls)
+	}
+	latest = get(t, c)
+	if len(latest.Finalizers) != 0 || latest.Status.Phase != "Terminating" {
+		t.Errorf("unexpected object %+v", latest)
+	}
+}
+
+func TestStatusCommitter_OptimisticLock(t *testing.T) {
+	ctx := context.TODO()
+	var calls []string
+	c := newClient(t, &calls)
+	stats := &Stats{}
+	commit := NewStatusCommitter[Foo, FooStatus](c.Status(), WithFinalizers(c), WithOptimisticLock(), WithStats(stats))
+
+	old := get(t, c)
+
+	// in the meantime another writer changes the object
+	other := old.DeepCopyObject().(*Foo)
+	other.Status.Phase = "Failed"
+	if err := c.Status().Update(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	obj := old.DeepCopyObject().(*Foo)
+	obj.Status.Phase = "Running"
+	if err := commit(ctx, old, obj); !kerr.IsConflict(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if phase := get(t, c).Status.Phase; phase != "Failed" {
+		t.Errorf("expected phase of the other writer to be kept, got %s", phase)
+	}
+
+	// the resourceVersion of the first write is used for the metadata patch
+	old = get(t, c)
+	obj = old.DeepCopyObject().(*Foo)
+	obj.Finalizers = []string{"test.kmodules.xyz/cleanup"}
+	obj.Status.Phase = "Running"
+	if err := commit(ctx, old, obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj.ResourceVersion != get(t, c).ResourceVersion {
+		t.Errorf("expected resourceVersion of obj to be updated")
+	}
+
+	if err := commit(ctx, obj, obj.DeepCopyObject().(*Foo)); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Written() != 1 || stats.Failed() != 1 || stats.Skipped() != 1 {
+		t.Errorf("unexpected stats written=%d failed=%d skipped=%d", stats.Written(), stats.Failed(), stats.Skipped())
+	}
+}
+
+type applyPatcher struct {
+	obj   *unstructured.Unstructured
+	patch client.Patch
+	opts  client.SubResourcePatchOptions
+}
+
+func (p *applyPatcher) Patch(_ context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	p.obj = obj.(*unstructured.Unstructured).DeepCopy()
+	p.patch = patch
+	p.opts.ApplyOptions(opts)
+	obj.SetResourceVersion("2")
+	return nil
+}
+
+func TestStatusCommitter_ServerSideApply(t *testing.T) {
+	p := &applyPatcher{}
+	commit := NewStatusCommitter[Foo, FooStatus](p, WithServerSideApply("test-manager", true, newScheme()), WithOptimisticLock())
+
+	old := newFoo()
+	old.ResourceVersion = "1"
+	old.Labels = map[string]string{"app": "foo"}
+	obj := old.DeepCopyObject().(*Foo)
+	obj.Status.Phase = "Running"
+	if err := commit(context.TODO(), old, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.patch != client.Apply {
+		t.Errorf("expected apply patch, got %s", p.patch.Type())
+	}
+	if p.opts.FieldManager != "test-manager" || p.opts.Force == nil || !*p.opts.Force {
+		t.Errorf("unexpected apply options %+v", p.opts)
+	}
+	if p.obj.GroupVersionKind() != fooGVK || p.obj.GetResourceVersion() != "1" || p.obj.GetLabels() != nil {
+		t.Errorf("unexpected apply configuration %v", p.obj.Object)
+	}
+	if phase, _, _ := unstructured.NestedString(p.obj.Object, "status", "phase"); phase != "Running" {
+		t.Errorf("expected status to be applied, got %v", p.obj.Object)
+	}
+	if obj.ResourceVersion != "2" {
+		t.Errorf("expected resourceVersion to be updated, got %s", obj.ResourceVersion)
+	}
+}
+
+func TestWithServerSideApply_EmptyFieldOwner(t *testing.T) {
+	var calls []string
+	c := newClient(t, &calls)
+	stats := &Stats{}
+	commit := NewStatusCommitter[Foo, FooStatus](c.Status(), WithServerSideApply("", false, newScheme()), WithStats(stats))
+
+	old := get(t, c)
+	obj := old.DeepCopyObject().(*Foo)
+	obj.Status.Phase = "Running"
+	if err := commit(context.TODO(), old, obj); err == nil {
+		t.Errorf("expected error for empty field owner")
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected no writes, found %v", calls)
+	}
+	if stats.Failed() != 1 {
+		t.Errorf("expected 1 failed commit, found %d", stats.Failed())
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package committer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectPatcher is the Patch API of the main resource, used to commit metadata changes.
+type ObjectPatcher interface {
+	Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error
+}
+
+type options struct {
+	objectPatcher ObjectPatcher
+	finalizers    bool
+	annotations   bool
+
+	optimisticLock bool
+
+	serverSideApply bool
+	fieldOwner      string
+	force           bool
+	scheme          *runtime.Scheme
+
+	stats *Stats
+
+	// err is returned by every commit if the options are invalid.
+	err error
+}
+
+// Option configures a status committer.
+type Option func(*options)
+
+// WithFinalizers commits changes to the finalizers of the object alongside the status.
+// Finalizers are added before and removed after the status is committed.
+func WithFinalizers(p ObjectPatcher) Option {
+	return func(o *options) {
+		o.objectPatcher = p
+		o.finalizers = true
+	}
+}
+
+// WithAnnotations commits changes to the annotations of the object alongside the status.
+func WithAnnotations(p ObjectPatcher) Option {
+	return func(o *options) {
+		o.objectPatcher = p
+		o.annotations = true
+	}
+}
+
+// WithOptimisticLock includes the resourceVersion of the old object in the patches, so that
+// the commit fails with a conflict if the object was changed in the meantime.
+func WithOptimisticLock() Option {
+	return func(o *options) {
+		o.optimisticLock = true
+	}
+}
+
+// WithServerSideApply commits the status using server-side apply with the given field owner.
+// The scheme is used to detect the GroupVersionKind of typed objects whose TypeMeta is
+// not set; it may be nil if the objects always have apiVersion and kind set.
+// An empty fieldOwner is rejected by the API server, so every commit returns an error in that case.
+func WithServerSideApply(fieldOwner string, force bool, scheme *runtime.Scheme) Option {
+	return func(o *options) {
+		if fieldOwner == "" {
+			o.err = errors.New("server-side apply requires a non-empty field owner")
+			return
+		}
+		o.serverSideApply = true
+		o.fieldOwner = fieldOwner
+		o.force = force
+		o.scheme = scheme
+	}
+}
+
+// WithStats records the outcome of every commit in the given Stats.
+func WithStats(s *Stats) Option {
+	return func(o *options) {
+		o.stats = s
+	}
+}
+
+// Stats counts the commits made by a status committer.
+type Stats struct {
+	skipped atomic.Int64
+	written atomic.Int64
+	failed  atomic.Int64
+}
+
+// Skipped returns the number of commits skipped because nothing changed.
+func (s *Stats) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+// Written returns the number of commits that wrote changes to the API server.
+func (s *Stats) Written() int64 {
+	return s.written.Load()
+}
+
+// Failed returns the number of commits that returned an error.
+func (s *Stats) Failed() int64 {
+	return s.failed.Load()
+}
+
+func (s *Stats) record(written bool, err error) {
+	if s == nil {
+		return
+	}
+	switch {
+	case err != nil:
+		s.failed.Add(1)
+	case written:
+		s.written.Add(1)
+	default:
+		s.skipped.Add(1)
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// EventReasonConditionChanged is the reason used for events emitted by the EventObserver.
+const EventReasonConditionChanged = "ConditionChanged"
+
+// Transition describes a change in the state of a condition.
+type Transition struct {
+	Type kmapi.ConditionType
+	// Previous is nil if the condition has been added.
+	Previous *kmapi.Condition
+	// Current is nil if the condition has been removed.
+	Current *kmapi.Condition
+}
+
+// StatusChanged returns true if the transition flips the status or the severity of the condition.
+// A missing condition is considered to have Status=Unknown.
+func (t Transition) StatusChanged() bool {
+	prevStatus, prevSeverity := metav1.ConditionUnknown, kmapi.ConditionSeverityNone
+	if t.Previous != nil {
+		prevStatus, prevSeverity = t.Previous.Status, t.Previous.Severity
+	}
+	curStatus, curSeverity := metav1.ConditionUnknown, kmapi.ConditionSeverityNone
+	if t.Current != nil {
+		curStatus, curSeverity = t.Current.Status, t.Current.Severity
+	}
+	return prevStatus != curStatus || prevSeverity != curSeverity
+}
+
+// TransitionObserver is notified when the state of a condition changes.
+type TransitionObserver interface {
+	ObserveTransition(obj Getter, t Transition)
+}
+
+// TransitionObserverFunc is an adapter to use an ordinary function as a TransitionObserver.
+type TransitionObserverFunc func(obj Getter, t Transition)
+
+func (f TransitionObserverFunc) ObserveTransition(obj Getter, t Transition) {
+	f(obj, t)
+}
+
+// WithObservers returns a Setter that notifies the observers whenever the state
+// of one of the conditions of the given object is changed via Set, Mark*, SetSummary,
+// SetMirror, SetAggregate, Delete or Patch.Apply.
+func WithObservers(to Setter, observers ...TransitionObserver) Setter {
+	if len(observers) == 0 {
+		return to
+	}
+	return &observedSetter{Setter: to, observers: observers}
+}
+
+type observedSetter struct {
+	Setter
+	observers []TransitionObserver
+}
+
+var _ Setter = &observedSetter{}
+
+// GetConditions returns a copy of the conditions so that in place changes
+// made by the setters can be detected in SetConditions.
+func (o *observedSetter) GetConditions() kmapi.Conditions {
+	return o.Setter.GetConditions().DeepCopy()
+}
+
+func (o *observedSetter) SetConditions(conditions kmapi.Conditions) {
+	before := o.Setter.GetConditions().DeepCopy()
+	o.Setter.SetConditions(conditions)
+
+	var transitions []Transition
+	for i := range conditions {
+		current := conditions[i]
+		var previous *kmapi.Condition
+		for j := range before {
+			if before[j].Type == current.Type {
+				previous = &before[j]
+				break
+			}
+		}
+		if previous != nil && hasSameState(previous, &current) {
+			continue
+		}
+		transitions = append(transitions, Transition{Type: current.Type, Previous: previous, Current: &current})
+	}
+	for i := range before {
+		previous := before[i]
+		if !hasConditionType(conditions, previous.Type) {
+			transitions = append(transitions, Transition{Type: previous.Type, Previous: &previous})
+		}
+	}
+
+	for _, t := range transitions {
+		for _, observer := range o.observers {
+			observer.ObserveTransition(o.Setter, t)
+		}
+	}
+}
+
+func hasConditionType(conditions kmapi.Conditions, t kmapi.ConditionType) bool {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// History is a TransitionObserver that keeps a bounded, in memory history of the
+// states of each condition type per object. Objects are identified by UID.
+type History struct {
+	limit int
+
+	mu    sync.RWMutex
+	items map[types.UID]map[kmapi.ConditionType][]kmapi.Condition
+}
+
+var _ TransitionObserver = &History{}
+
+// NewHistory returns a History that retains up to limit states per condition type.
+func NewHistory(limit int) *History {
+	if limit < 1 {
+		limit = 1
+	}
+	return &History{
+		limit: limit,
+		items: map[types.UID]map[kmapi.ConditionType][]kmapi.Condition{},
+	}
+}
+
+func (h *History) ObserveTransition(obj Getter, t Transition) {
+	if t.Current == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	byType, ok := h.items[obj.GetUID()]
+	if !ok {
+		byType = map[kmapi.ConditionType][]kmapi.Condition{}
+		h.items[obj.GetUID()] = byType
+	}
+	states := append(byType[t.Type], *t.Current.DeepCopy())
+	if len(states) > h.limit {
+		states = states[len(states)-h.limit:]
+	}
+	byType[t.Type] = states
+}
+
+// Get returns the recorded states of the condition with the given type, oldest first.
+func (h *History) Get(obj metav1.Object, t kmapi.ConditionType) kmapi.Conditions {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	states := h.items[obj.GetUID()][t]
+	if len(states) == 0 {
+		return nil
+	}
+	return kmapi.Conditions(states).DeepCopy()
+}
+
+// Forget removes the recorded history of the given object. It should be called
+// once the object has been deleted.
+func (h *History) Forget(obj metav1.Object) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.items, obj.GetUID())
+}
+
+// EventObserver is a TransitionObserver that emits a Kubernetes Event whenever a
+// condition flips status or severity.
+type EventObserver struct {
+	recorder record.EventRecorder
+}
+
+var _ TransitionObserver = &EventObserver{}
+
+// NewEventObserver returns an EventObserver that records events using the given recorder.
+func NewEventObserver(recorder record.EventRecorder) *EventObserver {
+	return &EventObserver{recorder: recorder}
+}
+
+func (e *EventObserver) ObserveTransition(obj Getter, t Transition) {
+	if t.Current == nil || !t.StatusChanged() {
+		return
+	}
+
+	eventType := core.EventTypeNormal
+	if t.Current.Status == metav1.ConditionFalse &&
+		(t.Current.Severity == kmapi.ConditionSeverityError || t.Current.Severity == kmapi.ConditionSeverityWarning) {
+		eventType = core.EventTypeWarning
+	}
+	e.recorder.Event(obj, eventType, EventReasonConditionChanged, transitionMessage(t))
+}
+
+func transitionMessage(t Transition) string {
+	var sb strings.Builder
+	if t.Previous == nil {
+		_, _ = fmt.Fprintf(&sb, "Condition %s set to %s", t.Type, formatState(t.Current))
+	} else {
+		_, _ = fmt.Fprintf(&sb, "Condition %s changed from %s to %s", t.Type, formatState(t.Previous), formatState(t.Current))
+	}
+	if t.Current.Reason != "" {
+		_, _ = fmt.Fprintf(&sb, ", reason: %s", t.Current.Reason)
+	}
+	if t.Current.Message != "" {
+		_, _ = fmt.Fprintf(&sb, ", message: %s", t.Current.Message)
+	}
+	return sb.String()
+}
+
+func formatState(c *kmapi.Condition) string {
+	if c.Severity == kmapi.ConditionSeverityNone {
+		return string(c.Status)
+	}
+	return fmt.Sprintf("%s(%s)", c.Status, c.Severity)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestWithObservers(t *testing.T) {
+	g := NewWithT(t)
+
+	var transitions []Transition
+	obj := setterWithConditions(true1)
+	target := WithObservers(obj, TransitionObserverFunc(func(_ Getter, t Transition) {
+		transitions = append(transitions, t)
+	}))
+
+	// no change in state
+	MarkTrue(target, "true1")
+	g.Expect(transitions).To(BeEmpty())
+
+	MarkFalse(target, "true1", "reason", kmapi.ConditionSeverityError, "message")
+	g.Expect(transitions).To(HaveLen(1))
+	g.Expect(transitions[0].Previous.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(transitions[0].Current.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(transitions[0].StatusChanged()).To(BeTrue())
+	g.Expect(IsFalse(obj, "true1")).To(BeTrue())
+
+	MarkFalse(target, "true1", "reason", kmapi.ConditionSeverityError, "another message")
+	g.Expect(transitions).To(HaveLen(2))
+	g.Expect(transitions[1].StatusChanged()).To(BeFalse())
+
+	Set(target, unknown1)
+	g.Expect(transitions).To(HaveLen(3))
+	g.Expect(transitions[2].Previous).To(BeNil())
+
+	Delete(target, "unknown1")
+	g.Expect(transitions).To(HaveLen(4))
+	g.Expect(transitions[3].Current).To(BeNil())
+}
+
+func TestHistory(t *testing.T) {
+	g := NewWithT(t)
+
+	h := NewHistory(2)
+	obj := setterWithConditions()
+	obj.SetUID("uid")
+	target := WithObservers(obj, h)
+
+	MarkTrue(target, kmapi.ReadyCondition)
+	MarkFalse(target, kmapi.ReadyCondition, "r1", kmapi.ConditionSeverityWarning, "m1")
+	MarkFalse(target, kmapi.ReadyCondition, "r2", kmapi.ConditionSeverityError, "m2")
+
+	states := h.Get(obj, kmapi.ReadyCondition)
+	g.Expect(states).To(HaveLen(2))
+	g.Expect(states[0].Reason).To(Equal("r1"))
+	g.Expect(states[1].Reason).To(Equal("r2"))
+
+	h.Forget(obj)
+	g.Expect(h.Get(obj, kmapi.ReadyCondition)).To(BeEmpty())
+}
+
+func TestEventObserver(t *testing.T) {
+	g := NewWithT(t)
+
+	recorder := record.NewFakeRecorder(10)
+	target := WithObservers(setterWithConditions(), NewEventObserver(recorder))
+
+	MarkTrue(target, kmapi.ReadyCondition)
+	g.Expect(<-recorder.Events).To(Equal("Normal ConditionChanged Condition Ready set to True"))
+
+	// reason or message change does not emit an event
+	MarkFalse(target, kmapi.ReadyCondition, "NotReady", kmapi.ConditionSeverityError, "m1")
+	MarkFalse(target, kmapi.ReadyCondition, "NotReady", kmapi.ConditionSeverityError, "m2")
+	g.Expect(<-recorder.Events).To(Equal("Warning ConditionChanged Condition Ready changed from True to False(Error), reason: NotReady, message: m1"))
+	g.Expect(recorder.Events).To(BeEmpty())
+}
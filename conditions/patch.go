@@ -17,8 +17,10 @@ limitations under the License.
 package conditions
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	kmapi "kmodules.xyz/client-go/api/v1"
 
@@ -80,6 +82,31 @@ func NewPatch(before Getter, after Getter) Patch {
 	return patch
 }
 
+// ConflictError is returned when a condition patch can not be applied because the
+// conditions were modified by a different process.
+type ConflictError struct {
+	// Conditions lists the types of the conflicting conditions.
+	Conditions []kmapi.ConditionType
+	messages   []string
+}
+
+func newConflictError(t kmapi.ConditionType, format string, args ...interface{}) *ConflictError {
+	return &ConflictError{
+		Conditions: []kmapi.ConditionType{t},
+		messages:   []string{fmt.Sprintf(format, args...)},
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return strings.Join(e.messages, "; ")
+}
+
+// IsConflict returns true if the error is a ConflictError.
+func IsConflict(err error) bool {
+	var ce *ConflictError
+	return errors.As(err, &ce)
+}
+
 // applyOptions allows to set strategies for patch apply.
 type applyOptions struct {
 	ownedConditions []kmapi.ConditionType
@@ -138,7 +165,7 @@ func (p Patch) Apply(latest Setter, options ...ApplyOption) error {
 			if latestCondition := Get(latest, conditionPatch.After.Type); latestCondition != nil {
 				// If latest and after agree on the change, then it is a conflict.
 				if !hasSameState(latestCondition, conditionPatch.After) {
-					return fmt.Errorf("error patching conditions: The condition %q was modified by a different process and this caused a merge/AddCondition conflict: %v", conditionPatch.After.Type, cmp.Diff(latestCondition, conditionPatch.After))
+					return newConflictError(conditionPatch.After.Type, "error patching conditions: The condition %q was modified by a different process and this caused a merge/AddCondition conflict: %v", conditionPatch.After.Type, cmp.Diff(latestCondition, conditionPatch.After))
 				}
 				// otherwise, the latest is already as intended.
 				// NOTE: We are preserving LastTransitionTime from the latest in order to avoid altering the existing value.
@@ -158,14 +185,14 @@ func (p Patch) Apply(latest Setter, options ...ApplyOption) error {
 
 			// If the condition does not exist anymore on the latest, this is a conflict.
 			if latestCondition == nil {
-				return fmt.Errorf("error patching conditions: The condition %q was deleted by a different process and this caused a merge/ChangeCondition conflict", conditionPatch.After.Type)
+				return newConflictError(conditionPatch.After.Type, "error patching conditions: The condition %q was deleted by a different process and this caused a merge/ChangeCondition conflict", conditionPatch.After.Type)
 			}
 
 			// If the condition on the latest is different from the base condition, check if
 			// the after state corresponds to the desired value. If not this is a conflict (unless we should ignore conflicts for this condition type).
 			if !reflect.DeepEqual(latestCondition, conditionPatch.Before) {
 				if !hasSameState(latestCondition, conditionPatch.After) {
-					return fmt.Errorf("error patching conditions: The condition %q was modified by a different process and this caused a merge/ChangeCondition conflict: %v", conditionPatch.After.Type, cmp.Diff(latestCondition, conditionPatch.After))
+					return newConflictError(conditionPatch.After.Type, "error patching conditions: The condition %q was modified by a different process and this caused a merge/ChangeCondition conflict: %v", conditionPatch.After.Type, cmp.Diff(latestCondition, conditionPatch.After))
 				}
 				// Otherwise the latest is already as intended.
 				// NOTE: We are preserving LastTransitionTime from the latest in order to avoid altering the existing value.
@@ -185,7 +212,7 @@ func (p Patch) Apply(latest Setter, options ...ApplyOption) error {
 			// if so then this is a conflict.
 			if latestCondition := Get(latest, conditionPatch.Before.Type); latestCondition != nil {
 				if !hasSameState(latestCondition, conditionPatch.Before) {
-					return fmt.Errorf("error patching conditions: The condition %q was modified by a different process and this caused a merge/RemoveCondition conflict: %v", conditionPatch.Before.Type, cmp.Diff(latestCondition, conditionPatch.Before))
+					return newConflictError(conditionPatch.Before.Type, "error patching conditions: The condition %q was modified by a different process and this caused a merge/RemoveCondition conflict: %v", conditionPatch.Before.Type, cmp.Diff(latestCondition, conditionPatch.Before))
 				}
 			}
 			// Otherwise the latest and after agreed on the delete operation, so there's nothing to change.
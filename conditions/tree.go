@@ -0,0 +1,257 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// Tree is an object and its dependent objects, used to present the
+// conditions of the object hierarchy, eg, in the form of `clusterctl describe`.
+type Tree struct {
+	Object   Getter
+	Children []*Tree
+}
+
+// NewTree returns a Tree for the given object and its children.
+func NewTree(obj Getter, children ...*Tree) *Tree {
+	return &Tree{Object: obj, Children: children}
+}
+
+// Add appends the children to the tree and returns the tree.
+func (t *Tree) Add(children ...*Tree) *Tree {
+	t.Children = append(t.Children, children...)
+	return t
+}
+
+// Ready returns the Ready condition of the object. If the object does not have
+// a Ready condition, it is computed by aggregating the Ready conditions of the
+// children recursively. If none of them have a Ready condition, nil is returned.
+func (t *Tree) Ready() *kmapi.Condition {
+	if c := Get(t.Object, kmapi.ReadyCondition); c != nil {
+		return c
+	}
+
+	from := make([]Getter, 0, len(t.Children))
+	for _, child := range t.Children {
+		if c := child.Ready(); c != nil {
+			from = append(from, &readyGetter{Getter: child.Object, ready: *c})
+		}
+	}
+	if len(from) == 0 {
+		return nil
+	}
+	return aggregate(from, kmapi.ReadyCondition, AddSourceRef())
+}
+
+// readyGetter overrides the conditions of an object with a rolled up Ready condition.
+type readyGetter struct {
+	Getter
+	ready kmapi.Condition
+}
+
+func (r *readyGetter) GetConditions() kmapi.Conditions {
+	return kmapi.Conditions{r.ready}
+}
+
+// TreeNode is the serializable form of a Tree, suitable for dashboards.
+type TreeNode struct {
+	Object kmapi.ObjectID `json:"object"`
+	// Ready is the Ready condition of the object or the roll up of the Ready conditions of its children.
+	Ready *kmapi.Condition `json:"ready,omitempty"`
+	// Conditions lists the conditions of the object other than Ready.
+	Conditions kmapi.Conditions `json:"conditions,omitempty"`
+	Children   []TreeNode       `json:"children,omitempty"`
+}
+
+// Node returns the serializable form of the tree.
+func (t *Tree) Node() TreeNode {
+	node := TreeNode{
+		Object:     objectID(t.Object),
+		Ready:      t.Ready(),
+		Conditions: otherConditions(t.Object),
+	}
+	for _, child := range t.Children {
+		node.Children = append(node.Children, child.Node())
+	}
+	return node
+}
+
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Node())
+}
+
+// RenderOptions defines the options for rendering a Tree as text.
+type RenderOptions struct {
+	// ShowConditions lists the conditions of every object in addition to the Ready condition.
+	ShowConditions bool
+	// Color uses ANSI escape codes to color the status of conditions by severity.
+	Color bool
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBlue   = "\x1b[34m"
+	colorGray   = "\x1b[90m"
+)
+
+var treeHeader = []string{"NAME", "READY", "SEVERITY", "REASON", "SINCE", "MESSAGE"}
+
+type treeRow struct {
+	columns   []string
+	condition *kmapi.Condition
+}
+
+// Render writes the tree in a human readable form.
+func (t *Tree) Render(w io.Writer, opts RenderOptions) error {
+	rows := []treeRow{{columns: treeHeader}}
+	rows = t.appendRows(rows, "", "", opts, time.Now())
+
+	widths := make([]int, len(treeHeader))
+	for _, row := range rows {
+		for i, col := range row.columns {
+			if n := utf8.RuneCountInString(col); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, row := range rows {
+		var line strings.Builder
+		last := len(row.columns) - 1
+		for i, col := range row.columns {
+			if i == last {
+				line.WriteString(col)
+				break
+			}
+			cell := col + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(col)+2)
+			if opts.Color && row.condition != nil && (i == 1 || i == 2) {
+				cell = conditionColor(row.condition) + cell + colorReset
+			}
+			line.WriteString(cell)
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " "))
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (t *Tree) appendRows(rows []treeRow, prefix, childPrefix string, opts RenderOptions, now time.Time) []treeRow {
+	id := objectID(t.Object)
+	rows = append(rows, newTreeRow(prefix+id.Kind+"/"+id.Name, t.Ready(), now))
+
+	var conditions kmapi.Conditions
+	if opts.ShowConditions {
+		conditions = otherConditions(t.Object)
+	}
+	n := len(conditions) + len(t.Children)
+	i := 0
+	for c := range conditions {
+		i++
+		p, _ := treePrefixes(childPrefix, i == n)
+		rows = append(rows, newTreeRow(p+string(conditions[c].Type), &conditions[c], now))
+	}
+	for _, child := range t.Children {
+		i++
+		p, cp := treePrefixes(childPrefix, i == n)
+		rows = child.appendRows(rows, p, cp, opts, now)
+	}
+	return rows
+}
+
+func treePrefixes(prefix string, last bool) (string, string) {
+	if last {
+		return prefix + "└─", prefix + "  "
+	}
+	return prefix + "├─", prefix + "│ "
+}
+
+func newTreeRow(name string, c *kmapi.Condition, now time.Time) treeRow {
+	if c == nil {
+		return treeRow{columns: []string{name, "", "", "", "", ""}}
+	}
+	var since string
+	if !c.LastTransitionTime.IsZero() {
+		since = duration.HumanDuration(now.Sub(c.LastTransitionTime.Time))
+	}
+	return treeRow{
+		columns:   []string{name, string(c.Status), string(c.Severity), c.Reason, since, c.Message},
+		condition: c,
+	}
+}
+
+func conditionColor(c *kmapi.Condition) string {
+	switch c.Status {
+	case metav1.ConditionTrue:
+		return colorGreen
+	case metav1.ConditionFalse:
+		switch c.Severity {
+		case kmapi.ConditionSeverityError:
+			return colorRed
+		case kmapi.ConditionSeverityWarning:
+			return colorYellow
+		default:
+			return colorBlue
+		}
+	}
+	return colorGray
+}
+
+func otherConditions(obj Getter) kmapi.Conditions {
+	var out kmapi.Conditions
+	for _, c := range obj.GetConditions() {
+		if c.Type != kmapi.ReadyCondition {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// objectID returns the ObjectID of the object. If the type information is not
+// set, eg, for typed objects read via a client, the Go type name is used as kind.
+func objectID(obj Getter) kmapi.ObjectID {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	kind := gvk.Kind
+	if kind == "" {
+		kind = reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	}
+	if kind == "" {
+		kind = fmt.Sprintf("%T", obj)
+	}
+	return kmapi.ObjectID{
+		Group:     gvk.Group,
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestTree() *Tree {
+	child1 := newConditioned("child1")
+	child1.SetConditions(conditionList(TrueCondition(kmapi.ReadyCondition)))
+
+	child2 := newConditioned("child2")
+	child2.SetConditions(conditionList(
+		FalseCondition(kmapi.ReadyCondition, "NotAvailable", kmapi.ConditionSeverityError, "replicas unavailable"),
+		falseError1,
+	))
+
+	// parent does not have a Ready condition, so it is rolled up from the children.
+	parent := newConditioned("parent")
+	parent.SetConditions(conditionList(true1))
+
+	return NewTree(parent, NewTree(child1), NewTree(child2))
+}
+
+func TestTreeReady(t *testing.T) {
+	g := NewWithT(t)
+
+	ready := newTestTree().Ready()
+	g.Expect(ready).NotTo(BeNil())
+	g.Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(ready.Severity).To(Equal(kmapi.ConditionSeverityError))
+	g.Expect(ready.Reason).To(Equal("NotAvailable @ Foo/child2"))
+
+	g.Expect(NewTree(newConditioned("empty")).Ready()).To(BeNil())
+}
+
+func TestTreeRender(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(newTestTree().Render(&buf, RenderOptions{ShowConditions: true})).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`NAME             READY  SEVERITY  REASON                     SINCE  MESSAGE
+Foo/parent       False  Error     NotAvailable @ Foo/child2         1 of 2 completed
+├─true1          True
+├─Foo/child1     True
+└─Foo/child2     False  Error     NotAvailable                      replicas unavailable
+  └─falseError1  False  Error     reason falseError1                message falseError1
+`))
+}
+
+func TestTreeJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := json.Marshal(newTestTree())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var node TreeNode
+	g.Expect(json.Unmarshal(data, &node)).To(Succeed())
+	g.Expect(node.Object.Name).To(Equal("parent"))
+	g.Expect(node.Ready.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(node.Conditions).To(HaveLen(1))
+	g.Expect(node.Children).To(HaveLen(2))
+	g.Expect(node.Children[1].Conditions).To(HaveLen(1))
+}
@@ -0,0 +1,191 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"fmt"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// NoReasonReported is used as the reason of conditions written in metav1.Condition
+// format, since metav1.Condition requires a non-empty reason.
+const NoReasonReported = "NoReasonReported"
+
+type unstructuredOptions struct {
+	fields []string
+	metav1 bool
+}
+
+// UnstructuredOption defines an option for the unstructured Getter and Setter.
+type UnstructuredOption func(*unstructuredOptions)
+
+// WithConditionsPath sets the path of the conditions field. Defaults to status.conditions .
+func WithConditionsPath(fields ...string) UnstructuredOption {
+	return func(o *unstructuredOptions) {
+		o.fields = fields
+	}
+}
+
+// WithMetav1Conditions writes conditions in a format that is valid for CRDs that use metav1.Condition,
+// i.e. severity is dropped, an empty reason is replaced with NoReasonReported and an empty message is kept.
+func WithMetav1Conditions() UnstructuredOption {
+	return func(o *unstructuredOptions) {
+		o.metav1 = true
+	}
+}
+
+func newUnstructuredOptions(options []UnstructuredOption) unstructuredOptions {
+	opts := unstructuredOptions{fields: []string{"status", "conditions"}}
+	for _, o := range options {
+		o(&opts)
+	}
+	return opts
+}
+
+// UnstructuredGetter returns a Getter for the conditions of an unstructured object.
+func UnstructuredGetter(u *unstructured.Unstructured, options ...UnstructuredOption) Getter {
+	return &unstructuredWrapper{Unstructured: u, opts: newUnstructuredOptions(options)}
+}
+
+// UnstructuredSetter returns a Setter for the conditions of an unstructured object.
+func UnstructuredSetter(u *unstructured.Unstructured, options ...UnstructuredOption) Setter {
+	return &unstructuredWrapper{Unstructured: u, opts: newUnstructuredOptions(options)}
+}
+
+type unstructuredWrapper struct {
+	*unstructured.Unstructured
+	opts unstructuredOptions
+}
+
+var _ Setter = &unstructuredWrapper{}
+
+// GetConditions returns the conditions of the object. Conditions that can not be
+// decoded are logged and ignored.
+func (w *unstructuredWrapper) GetConditions() kmapi.Conditions {
+	conditions, err := decodeUnstructuredConditions(w.Unstructured, w.opts.fields)
+	if err != nil {
+		klog.ErrorS(err, "failed to read conditions", "kind", w.GetKind(), "namespace", w.GetNamespace(), "name", w.GetName())
+	}
+	return conditions
+}
+
+// SetConditions sets the conditions of the object. Conditions that can not be
+// encoded are logged and ignored. If the existing conditions can not be decoded,
+// the object is left unchanged, since overwriting them would lose data.
+func (w *unstructuredWrapper) SetConditions(conditions kmapi.Conditions) {
+	if _, err := decodeUnstructuredConditions(w.Unstructured, w.opts.fields); err != nil {
+		klog.ErrorS(err, "refusing to overwrite conditions that can not be decoded", "kind", w.GetKind(), "namespace", w.GetNamespace(), "name", w.GetName())
+		return
+	}
+	write := WriteUnstructuredConditions
+	if w.opts.metav1 {
+		write = writeMetav1Conditions
+	}
+	if err := write(w.Unstructured, conditions, w.opts.fields...); err != nil {
+		klog.ErrorS(err, "failed to write conditions", "kind", w.GetKind(), "namespace", w.GetNamespace(), "name", w.GetName())
+	}
+}
+
+// writeMetav1Conditions encodes the conditions as metav1.Condition, so that the required
+// reason and message fields are always present.
+func writeMetav1Conditions(u *unstructured.Unstructured, conditions kmapi.Conditions, fields ...string) error {
+	if len(fields) == 0 {
+		fields = []string{"status", "conditions"}
+	}
+	items := make([]interface{}, 0, len(conditions))
+	for _, c := range conditions {
+		mc := metav1.Condition{
+			Type:               string(c.Type),
+			Status:             c.Status,
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		}
+		if mc.Reason == "" {
+			mc.Reason = NoReasonReported
+		}
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&mc)
+		if err != nil {
+			return err
+		}
+		items = append(items, m)
+	}
+	return unstructured.SetNestedSlice(u.Object, items, fields...)
+}
+
+// ReadUnstructuredConditions decodes the conditions of an unstructured object from the given
+// field path. If no field path is given, status.conditions is used.
+func ReadUnstructuredConditions(u *unstructured.Unstructured, fields ...string) (kmapi.Conditions, error) {
+	conditions, err := decodeUnstructuredConditions(u, fields)
+	if err != nil {
+		return nil, err
+	}
+	return conditions, nil
+}
+
+// decodeUnstructuredConditions returns the conditions that could be decoded along
+// with an aggregate of the errors for the items that could not be decoded.
+func decodeUnstructuredConditions(u *unstructured.Unstructured, fields []string) (kmapi.Conditions, error) {
+	if len(fields) == 0 {
+		fields = []string{"status", "conditions"}
+	}
+	items, ok, err := unstructured.NestedSlice(u.Object, fields...)
+	if err != nil || !ok {
+		return nil, err
+	}
+	conditions := make(kmapi.Conditions, 0, len(items))
+	var errs []error
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Errorf("condition %d is of type %T, expected map", i, item))
+			continue
+		}
+		var c kmapi.Condition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &c); err != nil {
+			errs = append(errs, fmt.Errorf("condition %d: %w", i, err))
+			continue
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, utilerrors.NewAggregate(errs)
+}
+
+// WriteUnstructuredConditions encodes the conditions into an unstructured object at the given
+// field path. If no field path is given, status.conditions is used.
+func WriteUnstructuredConditions(u *unstructured.Unstructured, conditions kmapi.Conditions, fields ...string) error {
+	if len(fields) == 0 {
+		fields = []string{"status", "conditions"}
+	}
+	items := make([]interface{}, 0, len(conditions))
+	for i := range conditions {
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&conditions[i])
+		if err != nil {
+			return err
+		}
+		items = append(items, m)
+	}
+	return unstructured.SetNestedSlice(u.Object, items, fields...)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newUnstructuredFoo() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "foo/v1",
+			"kind":       "Foo",
+			"metadata": map[string]interface{}{
+				"name":       "foo",
+				"generation": int64(3),
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":               "Available",
+						"status":             "True",
+						"lastTransitionTime": "2024-01-01T00:00:00Z",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestUnstructuredGetterSetter(t *testing.T) {
+	g := NewWithT(t)
+
+	u := newUnstructuredFoo()
+	g.Expect(IsTrue(UnstructuredGetter(u), "Available")).To(BeTrue())
+	g.Expect(GetLastTransitionTime(UnstructuredGetter(u), "Available").UTC().Year()).To(Equal(2024))
+
+	setter := UnstructuredSetter(u)
+	MarkFalse(setter, "Progressing", "Stalled", kmapi.ConditionSeverityWarning, "waiting for %d replicas", 2)
+	SetSummary(setter)
+
+	conditions, err := ReadUnstructuredConditions(u)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions).To(HaveLen(3))
+	g.Expect(conditions[0].Type).To(Equal(kmapi.ReadyCondition))
+	g.Expect(conditions[0].Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(conditions[0].ObservedGeneration).To(Equal(int64(3)))
+	g.Expect(GetSeverity(UnstructuredGetter(u), "Progressing")).To(HaveValue(Equal(kmapi.ConditionSeverityWarning)))
+}
+
+func TestUnstructuredSetterMetav1(t *testing.T) {
+	g := NewWithT(t)
+
+	u := newUnstructuredFoo()
+	setter := UnstructuredSetter(u, WithMetav1Conditions())
+	MarkFalse(setter, "Progressing", "", kmapi.ConditionSeverityWarning, "")
+
+	items, _, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, item := range items {
+		c := item.(map[string]interface{})
+		g.Expect(c).NotTo(HaveKey("severity"))
+		g.Expect(c).To(HaveKey("message"))
+		if c["type"] == "Progressing" {
+			g.Expect(c["reason"]).To(Equal(NoReasonReported))
+			g.Expect(c["message"]).To(Equal(""))
+		}
+	}
+
+	// the written conditions are valid metav1.Conditions and read back unchanged
+	var mcs []metav1.Condition
+	for _, item := range items {
+		var mc metav1.Condition
+		g.Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(item.(map[string]interface{}), &mc)).To(Succeed())
+		mcs = append(mcs, mc)
+	}
+	g.Expect(mcs).To(HaveLen(2))
+	before := UnstructuredGetter(u).GetConditions()
+	setter.SetConditions(before)
+	g.Expect(UnstructuredGetter(u).GetConditions()).To(Equal(before))
+	g.Expect(GetMessage(UnstructuredGetter(u), "Progressing")).To(Equal(""))
+}
+
+func TestUnstructuredConditionsPath(t *testing.T) {
+	g := NewWithT(t)
+
+	u := newUnstructuredFoo()
+	setter := UnstructuredSetter(u, WithConditionsPath("status", "health", "conditions"))
+	MarkTrue(setter, "Healthy")
+
+	g.Expect(IsTrue(UnstructuredGetter(u), "Healthy")).To(BeFalse())
+	conditions, err := ReadUnstructuredConditions(u, "status", "health", "conditions")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions).To(HaveLen(1))
+}
+
+func TestUnstructuredSetterMalformedConditions(t *testing.T) {
+	g := NewWithT(t)
+
+	u := newUnstructuredFoo()
+	items, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	items = append(items, map[string]interface{}{
+		"type":               "Degraded",
+		"status":             "True",
+		"lastTransitionTime": "yesterday",
+	})
+	g.Expect(unstructured.SetNestedSlice(u.Object, items, "status", "conditions")).To(Succeed())
+
+	// conditions that can be decoded are still readable
+	g.Expect(IsTrue(UnstructuredGetter(u), "Available")).To(BeTrue())
+	_, err := ReadUnstructuredConditions(u)
+	g.Expect(err).To(HaveOccurred())
+
+	// the malformed condition is not dropped by a write
+	MarkTrue(UnstructuredSetter(u), "Progressing")
+	after, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	g.Expect(after).To(Equal(items))
+}
@@ -0,0 +1,358 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// DefaultCacheTTL is the default duration discovery info is used before it is revalidated.
+	DefaultCacheTTL = 10 * time.Minute
+	// DefaultMaxClients is the default number of discovery clients kept by a Cache.
+	DefaultMaxClients = 256
+)
+
+// CacheOptions configures a discovery Cache.
+type CacheOptions struct {
+	// TTL is the duration discovery info is used before it is revalidated with the api server.
+	// Defaults to DefaultCacheTTL.
+	TTL time.Duration
+	// Dir, if set, persists the discovery info on disk under a sub directory per cache key,
+	// so that it can be reused by later processes, eg, CLI invocations.
+	Dir string
+	// MaxClients is the number of discovery clients kept in memory. The least recently used
+	// client is dropped when the limit is exceeded. Defaults to DefaultMaxClients.
+	MaxClients int
+}
+
+// Cache shares discovery info between clients of the same cluster and user. Entries are keyed
+// by the api server, its CA and a hash of the credentials, so the cache is safe to use by
+// multi-tenant servers that talk to many clusters on behalf of many users. The key is computed
+// without contacting the api server. Responses are cached by an http cache, so expired entries
+// are revalidated using ETags and, if supported by the api server, fetched using aggregated
+// discovery. Entries persisted on disk also record the cluster UID and server version, which
+// are checked once the TTL expires; the entry is discarded if either has changed.
+type Cache struct {
+	opts CacheOptions
+
+	lock    sync.Mutex
+	clients *lru.Cache
+}
+
+// NewCache returns a new discovery Cache.
+func NewCache(opts CacheOptions) *Cache {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+	if opts.MaxClients <= 0 {
+		opts.MaxClients = DefaultMaxClients
+	}
+	return &Cache{
+		opts:    opts,
+		clients: lru.New(opts.MaxClients),
+	}
+}
+
+// DiscoveryClientForConfig returns the cached discovery client for the cluster and user of cfg.
+func (c *Cache) DiscoveryClientForConfig(cfg *rest.Config) (discovery.CachedDiscoveryInterface, error) {
+	key := CacheKey(cfg)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if client, ok := c.clients.Get(key); ok {
+		return client.(discovery.CachedDiscoveryInterface), nil
+	}
+	client, err := c.newClient(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+	c.clients.Add(key, client)
+	return client, nil
+}
+
+// RESTMapperForConfig returns a RESTMapper backed by the cached discovery client for cfg.
+func (c *Cache) RESTMapperForConfig(cfg *rest.Config) (meta.RESTMapper, error) {
+	client, err := c.DiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(client), nil
+}
+
+func (c *Cache) newClient(cfg *rest.Config, key string) (discovery.CachedDiscoveryInterface, error) {
+	if c.opts.Dir == "" {
+		cfg = rest.CopyConfig(cfg)
+		cache := httpcache.NewMemoryCache()
+		cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &httpcache.Transport{Transport: rt, Cache: cache, MarkCachedResponses: true}
+		})
+		dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &ttlClient{
+			CachedDiscoveryInterface: memory.NewMemCacheClient(dc),
+			ttl:                      c.opts.TTL,
+		}, nil
+	}
+
+	dir := filepath.Join(c.opts.Dir, key)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	dc, err := disk.NewCachedDiscoveryClientForConfig(cfg, filepath.Join(dir, "discovery"), filepath.Join(dir, "http"), c.opts.TTL)
+	if err != nil {
+		return nil, err
+	}
+	return &lockedClient{
+		CachedDiscoveryInterface: dc,
+		lockFile:                 filepath.Join(dir, ".lock"),
+		identityFile:             filepath.Join(dir, "cluster"),
+		config:                   rest.CopyConfig(cfg),
+		ttl:                      c.opts.TTL,
+	}, nil
+}
+
+// CacheKey returns the key of the discovery info of the cluster and user of cfg. It is
+// computed from the api server address, its CA and the credentials of cfg, without
+// contacting the api server. Credentials injected by cfg.WrapTransport are not included.
+func CacheKey(cfg *rest.Config) string {
+	h := sha256.New()
+	for _, s := range []string{cfg.Host, cfg.APIPath, cfg.ServerName, cfg.CAFile, string(cfg.CAData), credentialsHash(cfg)} {
+		_, _ = io.WriteString(h, s)
+		_, _ = h.Write([]byte{0})
+	}
+	if cfg.Insecure {
+		_, _ = h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// clusterIdentity returns the UID and server version of the cluster of cfg. If the user is
+// not allowed to read the kube-system namespace, the api server host is used in place of
+// the cluster UID.
+func clusterIdentity(cfg *rest.Config) (string, error) {
+	kc, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	info, err := kc.Discovery().ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	clusterID := cfg.Host
+	ns, err := kc.CoreV1().Namespaces().Get(context.TODO(), metav1.NamespaceSystem, metav1.GetOptions{})
+	if err == nil {
+		clusterID = string(ns.UID)
+	} else if !kerr.IsForbidden(err) && !kerr.IsUnauthorized(err) {
+		return "", err
+	}
+	return clusterID + "/" + info.GitVersion, nil
+}
+
+// credentialsHash returns a hash of everything in cfg that identifies the user.
+func credentialsHash(cfg *rest.Config) string {
+	h := sha256.New()
+	write := func(values ...string) {
+		for _, v := range values {
+			_, _ = io.WriteString(h, v)
+			_, _ = h.Write([]byte{0})
+		}
+	}
+	write(cfg.Host, cfg.APIPath, cfg.Username, cfg.Password, cfg.BearerToken, cfg.BearerTokenFile)
+	write(cfg.CertFile, cfg.KeyFile, string(cfg.CertData), string(cfg.KeyData))
+	write(cfg.Impersonate.UserName, cfg.Impersonate.UID)
+	write(cfg.Impersonate.Groups...)
+	for k, v := range cfg.Impersonate.Extra {
+		write(k)
+		write(v...)
+	}
+	if cfg.AuthProvider != nil {
+		write(cfg.AuthProvider.Name)
+		for k, v := range cfg.AuthProvider.Config {
+			write(k, v)
+		}
+	}
+	if cfg.ExecProvider != nil {
+		write(cfg.ExecProvider.Command)
+		write(cfg.ExecProvider.Args...)
+		for _, env := range cfg.ExecProvider.Env {
+			write(env.Name, env.Value)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ttlClient invalidates the wrapped in memory cache once the ttl has expired.
+type ttlClient struct {
+	discovery.CachedDiscoveryInterface
+	ttl time.Duration
+
+	lock        sync.Mutex
+	refreshedAt time.Time
+}
+
+func (c *ttlClient) expire() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if c.refreshedAt.IsZero() {
+		c.refreshedAt = now
+	} else if now.Sub(c.refreshedAt) > c.ttl {
+		klog.V(5).Info("discovery cache expired, revalidating")
+		c.CachedDiscoveryInterface.Invalidate()
+		c.refreshedAt = now
+	}
+}
+
+func (c *ttlClient) ServerGroups() (*metav1.APIGroupList, error) {
+	c.expire()
+	return c.CachedDiscoveryInterface.ServerGroups()
+}
+
+func (c *ttlClient) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	c.expire()
+	return c.CachedDiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func (c *ttlClient) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	c.expire()
+	return c.CachedDiscoveryInterface.ServerGroupsAndResources()
+}
+
+func (c *ttlClient) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	c.expire()
+	return c.CachedDiscoveryInterface.ServerPreferredResources()
+}
+
+func (c *ttlClient) ServerPreferredNamespacedResources() ([]*metav1.APIResourceList, error) {
+	c.expire()
+	return c.CachedDiscoveryInterface.ServerPreferredNamespacedResources()
+}
+
+func (c *ttlClient) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.CachedDiscoveryInterface.Invalidate()
+	c.refreshedAt = time.Now()
+}
+
+// lockedClient holds an exclusive file lock while the wrapped disk cache is read or written,
+// so that concurrent processes don't read partially written cache files.
+type lockedClient struct {
+	discovery.CachedDiscoveryInterface
+	lockFile string
+
+	// identityFile records the identity of the cluster the cache was filled from. It is
+	// checked once its modification time is older than ttl.
+	identityFile string
+	config       *rest.Config
+	ttl          time.Duration
+
+	identityLock sync.Mutex
+	checkedAt    time.Time
+}
+
+func (c *lockedClient) withLock(fn func()) {
+	unlock, err := lockFile(c.lockFile)
+	if err != nil {
+		klog.V(3).Infof("failed to lock discovery cache %s: %v", c.lockFile, err)
+	} else {
+		defer unlock()
+	}
+	c.checkIdentity()
+	fn()
+}
+
+// checkIdentity invalidates the cache if the cluster UID or server version changed since the
+// cache was filled. It is called with the file lock held.
+func (c *lockedClient) checkIdentity() {
+	c.identityLock.Lock()
+	defer c.identityLock.Unlock()
+
+	if c.checkedAt.IsZero() {
+		if fi, err := os.Stat(c.identityFile); err == nil {
+			c.checkedAt = fi.ModTime()
+		}
+	}
+	if time.Since(c.checkedAt) < c.ttl {
+		return
+	}
+
+	id, err := clusterIdentity(c.config)
+	if err != nil {
+		klog.V(3).Infof("failed to check identity of cluster %s: %v", c.config.Host, err)
+		return
+	}
+	if data, err := os.ReadFile(c.identityFile); err == nil && string(data) != id {
+		klog.V(3).Infof("cluster %s changed, discarding discovery cache", c.config.Host)
+		c.CachedDiscoveryInterface.Invalidate()
+	}
+	if err := os.WriteFile(c.identityFile, []byte(id), 0o600); err != nil {
+		klog.V(3).Infof("failed to write %s: %v", c.identityFile, err)
+	}
+	c.checkedAt = time.Now()
+}
+
+func (c *lockedClient) ServerGroups() (out *metav1.APIGroupList, err error) {
+	c.withLock(func() { out, err = c.CachedDiscoveryInterface.ServerGroups() })
+	return
+}
+
+func (c *lockedClient) ServerResourcesForGroupVersion(groupVersion string) (out *metav1.APIResourceList, err error) {
+	c.withLock(func() { out, err = c.CachedDiscoveryInterface.ServerResourcesForGroupVersion(groupVersion) })
+	return
+}
+
+func (c *lockedClient) ServerGroupsAndResources() (groups []*metav1.APIGroup, resources []*metav1.APIResourceList, err error) {
+	c.withLock(func() { groups, resources, err = c.CachedDiscoveryInterface.ServerGroupsAndResources() })
+	return
+}
+
+func (c *lockedClient) ServerPreferredResources() (out []*metav1.APIResourceList, err error) {
+	c.withLock(func() { out, err = c.CachedDiscoveryInterface.ServerPreferredResources() })
+	return
+}
+
+func (c *lockedClient) ServerPreferredNamespacedResources() (out []*metav1.APIResourceList, err error) {
+	c.withLock(func() { out, err = c.CachedDiscoveryInterface.ServerPreferredNamespacedResources() })
+	return
+}
@@ -0,0 +1,187 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/rest"
+)
+
+func newDiscoveryServer(t *testing.T, groupsCalls *int32) *httptest.Server {
+	write := func(w http.ResponseWriter, obj interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(obj); err != nil {
+			t.Error(err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, _ *http.Request) {
+		write(w, version.Info{GitVersion: "v1.30.0"})
+	})
+	mux.HandleFunc("/api/v1/namespaces/kube-system", func(w http.ResponseWriter, _ *http.Request) {
+		write(w, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": "kube-system", "uid": "cluster-uid"},
+		})
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(groupsCalls, 1)
+		write(w, metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+	})
+	mux.HandleFunc("/apis", func(w http.ResponseWriter, _ *http.Request) {
+		write(w, metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}})
+	})
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, _ *http.Request) {
+		write(w, metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"get", "list"}}},
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCacheKey(t *testing.T) {
+	alice := CacheKey(&rest.Config{Host: "https://example.com", BearerToken: "alice"})
+	bob := CacheKey(&rest.Config{Host: "https://example.com", BearerToken: "bob"})
+	otherCA := CacheKey(&rest.Config{Host: "https://example.com", BearerToken: "alice", TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca")}})
+	if alice == bob {
+		t.Errorf("expected different keys for different credentials")
+	}
+	if alice == otherCA {
+		t.Errorf("expected different keys for different CAs")
+	}
+
+	cfg := &rest.Config{Host: "https://example.com", BearerToken: "alice"}
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper { return rt })
+	if key := CacheKey(rest.CopyConfig(cfg)); key != alice {
+		t.Errorf("expected same key for copied config, found %s and %s", alice, key)
+	}
+}
+
+func TestCache(t *testing.T) {
+	for name, dir := range map[string]string{"memory": "", "disk": t.TempDir()} {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			srv := newDiscoveryServer(t, &calls)
+			defer srv.Close()
+
+			c := NewCache(CacheOptions{Dir: dir})
+			cfg := &rest.Config{Host: srv.URL, BearerToken: "alice"}
+			mapper, err := c.RESTMapperForConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := mapper.ResourceFor(schema.GroupVersionResource{Version: "v1", Resource: "pods"}); err != nil {
+				t.Fatal(err)
+			}
+
+			dc, err := c.DiscoveryClientForConfig(rest.CopyConfig(cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := dc.ServerResourcesForGroupVersion("v1"); err != nil {
+				t.Fatal(err)
+			}
+			if n := atomic.LoadInt32(&calls); n != 1 {
+				t.Errorf("expected discovery to be fetched once, found %d", n)
+			}
+
+			if dir != "" {
+				if _, err := os.Stat(filepath.Join(dir, CacheKey(cfg), "discovery")); err != nil {
+					t.Errorf("expected discovery info to be persisted: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCache_MaxClients(t *testing.T) {
+	c := NewCache(CacheOptions{MaxClients: 1})
+	alice, err := c.DiscoveryClientForConfig(&rest.Config{Host: "https://example.com", BearerToken: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DiscoveryClientForConfig(&rest.Config{Host: "https://example.com", BearerToken: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.clients.Len(); n != 1 {
+		t.Errorf("expected 1 cached client, found %d", n)
+	}
+	again, err := c.DiscoveryClientForConfig(&rest.Config{Host: "https://example.com", BearerToken: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == alice {
+		t.Errorf("expected evicted client to be recreated")
+	}
+}
+
+func TestCache_ClusterIdentity(t *testing.T) {
+	var calls int32
+	srv := newDiscoveryServer(t, &calls)
+	defer srv.Close()
+	dir := t.TempDir()
+	cfg := &rest.Config{Host: srv.URL, BearerToken: "alice"}
+
+	use := func() {
+		t.Helper()
+		dc, err := NewCache(CacheOptions{Dir: dir}).DiscoveryClientForConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dc.ServerResourcesForGroupVersion("v1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a later process reuses the persisted discovery info
+	use()
+	use()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected discovery to be fetched once, found %d", n)
+	}
+
+	// the cache is discarded once the recorded identity is checked and found changed
+	identityFile := filepath.Join(dir, CacheKey(cfg), "cluster")
+	if err := os.WriteFile(identityFile, []byte("other-cluster/v1.29.0"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-2 * DefaultCacheTTL)
+	if err := os.Chtimes(identityFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+	use()
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected discovery to be fetched again for a changed cluster, found %d", n)
+	}
+	if data, _ := os.ReadFile(identityFile); string(data) != "cluster-uid/v1.30.0" {
+		t.Errorf("unexpected cluster identity %q", data)
+	}
+}
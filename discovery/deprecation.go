@@ -0,0 +1,254 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"kmodules.xyz/client-go/tools/parser"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/client-go/discovery"
+)
+
+// APIDeprecation describes the deprecation of an apiVersion of a kind.
+type APIDeprecation struct {
+	APIVersion string
+	Kind       string
+	// DeprecatedIn is the Kubernetes release that deprecated the apiVersion, eg, v1.19 .
+	DeprecatedIn string
+	// RemovedIn is the Kubernetes release that stopped serving the apiVersion.
+	RemovedIn string
+	// ReplacementAPIVersion is the apiVersion to migrate to. It is empty if the kind
+	// was removed without a replacement.
+	ReplacementAPIVersion string
+}
+
+// KnownAPIDeprecations lists the deprecated apiVersions of the built-in Kubernetes kinds.
+// ref: https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var KnownAPIDeprecations = []APIDeprecation{
+	{APIVersion: "extensions/v1beta1", Kind: "DaemonSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Deployment", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "NetworkPolicy", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "networking.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: "v1.10", RemovedIn: "v1.16", ReplacementAPIVersion: "policy/v1beta1"},
+	{APIVersion: "apps/v1beta1", Kind: "Deployment", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "apps/v1beta1", Kind: "StatefulSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "DaemonSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "Deployment", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "StatefulSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", ReplacementAPIVersion: "apps/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", DeprecatedIn: "v1.16", RemovedIn: "v1.22", ReplacementAPIVersion: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", DeprecatedIn: "v1.16", RemovedIn: "v1.22", ReplacementAPIVersion: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", DeprecatedIn: "v1.16", RemovedIn: "v1.22", ReplacementAPIVersion: "apiextensions.k8s.io/v1"},
+	{APIVersion: "apiregistration.k8s.io/v1beta1", Kind: "APIService", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "apiregistration.k8s.io/v1"},
+	{APIVersion: "authentication.k8s.io/v1beta1", Kind: "TokenReview", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "authentication.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "LocalSubjectAccessReview", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "authorization.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SelfSubjectAccessReview", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "authorization.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SubjectAccessReview", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "authorization.k8s.io/v1"},
+	{APIVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "certificates.k8s.io/v1"},
+	{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "coordination.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Ingress", DeprecatedIn: "v1.14", RemovedIn: "v1.22", ReplacementAPIVersion: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "networking.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", DeprecatedIn: "v1.17", RemovedIn: "v1.22", ReplacementAPIVersion: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", DeprecatedIn: "v1.17", RemovedIn: "v1.22", ReplacementAPIVersion: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", DeprecatedIn: "v1.17", RemovedIn: "v1.22", ReplacementAPIVersion: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", DeprecatedIn: "v1.17", RemovedIn: "v1.22", ReplacementAPIVersion: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", DeprecatedIn: "v1.14", RemovedIn: "v1.22", ReplacementAPIVersion: "scheduling.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", DeprecatedIn: "v1.19", RemovedIn: "v1.22", ReplacementAPIVersion: "storage.k8s.io/v1"},
+	{APIVersion: "batch/v1beta1", Kind: "CronJob", DeprecatedIn: "v1.21", RemovedIn: "v1.25", ReplacementAPIVersion: "batch/v1"},
+	{APIVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", DeprecatedIn: "v1.21", RemovedIn: "v1.25", ReplacementAPIVersion: "discovery.k8s.io/v1"},
+	{APIVersion: "events.k8s.io/v1beta1", Kind: "Event", DeprecatedIn: "v1.19", RemovedIn: "v1.25", ReplacementAPIVersion: "events.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", DeprecatedIn: "v1.22", RemovedIn: "v1.25", ReplacementAPIVersion: "autoscaling/v2"},
+	{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", DeprecatedIn: "v1.21", RemovedIn: "v1.25", ReplacementAPIVersion: "policy/v1"},
+	{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: "v1.21", RemovedIn: "v1.25"},
+	{APIVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", DeprecatedIn: "v1.20", RemovedIn: "v1.25", ReplacementAPIVersion: "node.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", DeprecatedIn: "v1.23", RemovedIn: "v1.26", ReplacementAPIVersion: "autoscaling/v2"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", DeprecatedIn: "v1.23", RemovedIn: "v1.26", ReplacementAPIVersion: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", DeprecatedIn: "v1.23", RemovedIn: "v1.26", ReplacementAPIVersion: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", DeprecatedIn: "v1.24", RemovedIn: "v1.27", ReplacementAPIVersion: "storage.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", DeprecatedIn: "v1.26", RemovedIn: "v1.29", ReplacementAPIVersion: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", DeprecatedIn: "v1.26", RemovedIn: "v1.29", ReplacementAPIVersion: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", DeprecatedIn: "v1.29", RemovedIn: "v1.32", ReplacementAPIVersion: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", DeprecatedIn: "v1.29", RemovedIn: "v1.32", ReplacementAPIVersion: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// DeprecationStatus is the status of the apiVersion of a resource on a Kubernetes release.
+type DeprecationStatus string
+
+const (
+	DeprecationStatusDeprecated DeprecationStatus = "Deprecated"
+	DeprecationStatusRemoved    DeprecationStatus = "Removed"
+)
+
+// DeprecationFinding reports a resource that uses a deprecated or removed apiVersion.
+type DeprecationFinding struct {
+	Filename              string            `json:"filename,omitempty"`
+	APIVersion            string            `json:"apiVersion"`
+	Kind                  string            `json:"kind"`
+	Namespace             string            `json:"namespace,omitempty"`
+	Name                  string            `json:"name"`
+	Status                DeprecationStatus `json:"status"`
+	DeprecatedIn          string            `json:"deprecatedIn"`
+	RemovedIn             string            `json:"removedIn,omitempty"`
+	ReplacementAPIVersion string            `json:"replacementAPIVersion,omitempty"`
+}
+
+// DeprecationReport is the result of checking a set of manifests against a Kubernetes release.
+type DeprecationReport struct {
+	KubernetesVersion string               `json:"kubernetesVersion"`
+	Findings          []DeprecationFinding `json:"findings"`
+}
+
+// HasRemoved returns true if any resource uses an apiVersion that is not served by the
+// checked Kubernetes release. CI pipelines can use it to fail the build.
+func (r *DeprecationReport) HasRemoved() bool {
+	for _, f := range r.Findings {
+		if f.Status == DeprecationStatusRemoved {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as json.
+func (r *DeprecationReport) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// WriteTable writes the report as a human readable table.
+func (r *DeprecationReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FILE\tKIND\tNAMESPACE\tNAME\tAPI VERSION\tSTATUS\tREMOVED IN\tREPLACEMENT")
+	for _, f := range r.Findings {
+		replacement := f.ReplacementAPIVersion
+		if replacement == "" {
+			replacement = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.Filename, f.Kind, f.Namespace, f.Name, f.APIVersion, f.Status, f.RemovedIn, replacement)
+	}
+	return tw.Flush()
+}
+
+// DeprecationAdvisor reports resources that use deprecated or removed apiVersions.
+type DeprecationAdvisor struct {
+	deprecations map[string]APIDeprecation
+}
+
+// NewDeprecationAdvisor returns an advisor that knows about KnownAPIDeprecations and the
+// given deprecations, eg, for custom resources. The given deprecations take precedence.
+func NewDeprecationAdvisor(deprecations ...APIDeprecation) *DeprecationAdvisor {
+	a := &DeprecationAdvisor{
+		deprecations: make(map[string]APIDeprecation, len(KnownAPIDeprecations)+len(deprecations)),
+	}
+	for _, list := range [][]APIDeprecation{KnownAPIDeprecations, deprecations} {
+		for _, d := range list {
+			a.deprecations[d.APIVersion+"/"+d.Kind] = d
+		}
+	}
+	return a
+}
+
+// CheckServer checks the resources against the version of the api server.
+func (a *DeprecationAdvisor) CheckServer(client discovery.DiscoveryInterface, resources []parser.ResourceInfo) (*DeprecationReport, error) {
+	version, err := GetVersion(client)
+	if err != nil {
+		return nil, err
+	}
+	return a.Check(version, resources)
+}
+
+// Check checks the resources against the given Kubernetes version, eg, the version of the
+// live server or the target version of a planned upgrade.
+func (a *DeprecationAdvisor) Check(kubernetesVersion string, resources []parser.ResourceInfo) (*DeprecationReport, error) {
+	v, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+	*v, _ = v.SetPrerelease("")
+	*v, _ = v.SetMetadata("")
+
+	report := &DeprecationReport{
+		KubernetesVersion: kubernetesVersion,
+		Findings:          []DeprecationFinding{},
+	}
+	for _, ri := range resources {
+		d, ok := a.deprecations[ri.Object.GetAPIVersion()+"/"+ri.Object.GetKind()]
+		if !ok {
+			continue
+		}
+		status, err := d.statusIn(v)
+		if err != nil {
+			return nil, err
+		}
+		if status == "" {
+			continue
+		}
+		report.Findings = append(report.Findings, DeprecationFinding{
+			Filename:              ri.Filename,
+			APIVersion:            d.APIVersion,
+			Kind:                  d.Kind,
+			Namespace:             ri.Object.GetNamespace(),
+			Name:                  ri.Object.GetName(),
+			Status:                status,
+			DeprecatedIn:          d.DeprecatedIn,
+			RemovedIn:             d.RemovedIn,
+			ReplacementAPIVersion: d.ReplacementAPIVersion,
+		})
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		x, y := report.Findings[i], report.Findings[j]
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return strings.Compare(x.Kind+"/"+x.Namespace+"/"+x.Name, y.Kind+"/"+y.Namespace+"/"+y.Name) < 0
+	})
+	return report, nil
+}
+
+// statusIn returns the status of the apiVersion in the Kubernetes version v, or an empty
+// status if the apiVersion is not deprecated yet.
+func (d APIDeprecation) statusIn(v *semver.Version) (DeprecationStatus, error) {
+	if d.RemovedIn != "" {
+		removed, err := semver.NewVersion(d.RemovedIn)
+		if err != nil {
+			return "", err
+		}
+		if !v.LessThan(removed) {
+			return DeprecationStatusRemoved, nil
+		}
+	}
+	deprecated, err := semver.NewVersion(d.DeprecatedIn)
+	if err != nil {
+		return "", err
+	}
+	if !v.LessThan(deprecated) {
+		return DeprecationStatusDeprecated, nil
+	}
+	return "", nil
+}
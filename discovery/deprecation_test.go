@@ -0,0 +1,127 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"kmodules.xyz/client-go/tools/parser"
+)
+
+const deprecatedManifests = `
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: demo
+---
+apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+  namespace: demo
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: demo
+---
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: restricted
+`
+
+func TestDeprecationAdvisor_Check(t *testing.T) {
+	resources, err := parser.ListResources([]byte(deprecatedManifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewDeprecationAdvisor()
+
+	cases := []struct {
+		version  string
+		statuses map[string]DeprecationStatus
+		removed  bool
+	}{
+		{version: "v1.20.4", statuses: map[string]DeprecationStatus{}},
+		{version: "v1.23.0", statuses: map[string]DeprecationStatus{
+			"CronJob":                 DeprecationStatusDeprecated,
+			"HorizontalPodAutoscaler": DeprecationStatusDeprecated,
+			"PodSecurityPolicy":       DeprecationStatusDeprecated,
+		}},
+		{version: "v1.25.3-eks-1", removed: true, statuses: map[string]DeprecationStatus{
+			"CronJob":                 DeprecationStatusRemoved,
+			"HorizontalPodAutoscaler": DeprecationStatusDeprecated,
+			"PodSecurityPolicy":       DeprecationStatusRemoved,
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.version, func(t *testing.T) {
+			report, err := a.Check(c.version, resources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := map[string]DeprecationStatus{}
+			for _, f := range report.Findings {
+				statuses[f.Kind] = f.Status
+			}
+			if len(statuses) != len(c.statuses) {
+				t.Fatalf("expected %v, found %v", c.statuses, statuses)
+			}
+			for k, v := range c.statuses {
+				if statuses[k] != v {
+					t.Errorf("expected %s to be %s, found %s", k, v, statuses[k])
+				}
+			}
+			if report.HasRemoved() != c.removed {
+				t.Errorf("expected HasRemoved to be %v", c.removed)
+			}
+		})
+	}
+}
+
+func TestDeprecationReport_WriteJSON(t *testing.T) {
+	resources, err := parser.ListResources([]byte(deprecatedManifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := NewDeprecationAdvisor().Check("v1.26.0", resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out DeprecationReport
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Findings) != 3 {
+		t.Fatalf("expected 3 findings, found %+v", out.Findings)
+	}
+	for _, f := range out.Findings {
+		if f.Kind == "HorizontalPodAutoscaler" && (f.ReplacementAPIVersion != "autoscaling/v2" || f.RemovedIn != "v1.26") {
+			t.Errorf("unexpected finding %+v", f)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake builds fake discovery clients, RESTMappers and Cachables from a YAML
+// description of the api resources served by a cluster, so that version detection
+// code paths can be unit tested without a live api server.
+package fake
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"kmodules.xyz/client-go/client/apiutil"
+	disc "kmodules.xyz/client-go/discovery"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultVerbs are assigned to resources listed without any verbs.
+var DefaultVerbs = metav1.Verbs{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}
+
+// Cluster describes the api resources served by a fake cluster.
+//
+// The YAML representation looks like below. The first version listed for a group is
+// used as the preferred version of that group.
+//
+//	serverVersion: v1.29.2
+//	resources:
+//	- groupVersion: apps/v1
+//	  resources:
+//	  - name: deployments
+//	    singularName: deployment
+//	    kind: Deployment
+//	    namespaced: true
+//	    shortNames: [deploy]
+type Cluster struct {
+	ServerVersion string                    `json:"serverVersion,omitempty"`
+	Resources     []*metav1.APIResourceList `json:"resources"`
+}
+
+// Load parses a Cluster from YAML or JSON. The resource_lists.yaml file found in the
+// backups created by kmodules.xyz/client-go/tools/backup is also accepted.
+func Load(data []byte) (*Cluster, error) {
+	var c Cluster
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-")) || bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := yaml.Unmarshal(data, &c.Resources); err != nil {
+			return nil, errors.Wrap(err, "failed to parse resource lists")
+		}
+	} else if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, errors.Wrap(err, "failed to parse fake cluster")
+	}
+	if err := c.complete(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LoadFile parses a Cluster from the given file.
+func LoadFile(filename string) (*Cluster, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Load(data)
+	if err != nil {
+		return nil, errors.Wrap(err, filename)
+	}
+	return c, nil
+}
+
+// MustLoad is like Load but panics on error. It simplifies initialization of test fixtures.
+func MustLoad(data []byte) *Cluster {
+	c, err := Load(data)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *Cluster) complete() error {
+	for _, rl := range c.Resources {
+		gv, err := schema.ParseGroupVersion(rl.GroupVersion)
+		if err != nil {
+			return err
+		}
+		for i := range rl.APIResources {
+			r := &rl.APIResources[i]
+			if r.Name == "" || r.Kind == "" {
+				return errors.Errorf("name and kind are required for resources in group version %s", gv)
+			}
+			if r.SingularName == "" && !strings.ContainsRune(r.Name, '/') {
+				r.SingularName = strings.ToLower(r.Kind)
+			}
+			if len(r.Verbs) == 0 {
+				r.Verbs = DefaultVerbs
+			}
+		}
+	}
+	return nil
+}
+
+// Discovery returns a cached discovery client serving the resources of the cluster.
+// ServerPreferredResources is computed from the listed resources, unlike the fake
+// discovery client from client-go.
+func (c *Cluster) Discovery() discovery.CachedDiscoveryInterface {
+	fd := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: c.Resources,
+		},
+	}
+	if c.ServerVersion != "" {
+		fd.FakedServerVersion = &version.Info{
+			GitVersion: c.ServerVersion,
+		}
+		if major, minor, ok := splitVersion(c.ServerVersion); ok {
+			fd.FakedServerVersion.Major = major
+			fd.FakedServerVersion.Minor = minor
+		}
+	}
+	return memory.NewMemCacheClient(fd)
+}
+
+func splitVersion(v string) (string, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// RESTMapper returns a RESTMapper for the resources of the cluster.
+func (c *Cluster) RESTMapper() meta.RESTMapper {
+	return disc.NewRestMapper(c.Discovery())
+}
+
+// ResourceMapper returns a ResourceResolver for the resources of the cluster.
+func (c *Cluster) ResourceMapper() disc.ResourceResolver {
+	return disc.NewResourceMapperForDiscovery(c.Discovery())
+}
+
+// Cachable returns a Cachable for the resources of the cluster.
+func (c *Cluster) Cachable() apiutil.Cachable {
+	cachable, err := apiutil.NewCachable(c.Discovery())
+	if err != nil {
+		// the fake discovery client never fails
+		panic(err)
+	}
+	return cachable
+}
+
+// Client returns a fake controller-runtime client that uses the RESTMapper of the cluster.
+func (c *Cluster) Client(s *runtime.Scheme, objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(s).
+		WithRESTMapper(c.RESTMapper()).
+		WithObjects(objs...).
+		Build()
+}
@@ -0,0 +1,115 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"testing"
+
+	"kmodules.xyz/client-go/cluster"
+	disc "kmodules.xyz/client-go/discovery"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const clusterYAML = `
+serverVersion: v1.21.3
+resources:
+- groupVersion: v1
+  resources:
+  - name: pods
+    kind: Pod
+    namespaced: true
+    shortNames: [po]
+    categories: [all]
+  - name: pods/log
+    kind: Pod
+    namespaced: true
+    verbs: [get]
+- groupVersion: batch/v1beta1
+  resources:
+  - name: cronjobs
+    kind: CronJob
+    namespaced: true
+    shortNames: [cj]
+- groupVersion: project.openshift.io/v1
+  resources:
+  - name: projects
+    kind: Project
+    verbs: [get, list]
+`
+
+const resourceListsYAML = `
+- groupVersion: policy/v1beta1
+  resources:
+  - name: poddisruptionbudgets
+    singularName: poddisruptionbudget
+    kind: PodDisruptionBudget
+    namespaced: true
+    verbs: [get, list, watch]
+`
+
+func TestCluster(t *testing.T) {
+	c := MustLoad([]byte(clusterYAML))
+
+	dc := c.Discovery()
+	if ok, err := disc.HasGVK(dc, "batch/v1beta1", "CronJob"); err != nil || !ok {
+		t.Errorf("expected batch/v1beta1 CronJob to be served, got %v, %v", ok, err)
+	}
+	if ok, _ := disc.HasGVK(dc, "batch/v1", "CronJob"); ok {
+		t.Errorf("expected batch/v1 CronJob not to be served")
+	}
+	if v, err := disc.GetVersion(dc); err != nil || v != "v1.21.3" {
+		t.Errorf("expected server version v1.21.3, got %q, %v", v, err)
+	}
+
+	if !cluster.IsOpenShiftManaged(c.RESTMapper()) {
+		t.Errorf("expected cluster to be detected as OpenShift")
+	}
+
+	infos, err := c.ResourceMapper().Resolve("cj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Kind != "CronJob" {
+		t.Errorf("unexpected resolution of cj: %+v", infos)
+	}
+
+	cachable := c.Cachable()
+	if ok, err := cachable.GVR(schema.GroupVersionResource{Version: "v1", Resource: "pods"}); err != nil || !ok {
+		t.Errorf("expected pods to be cachable, got %v, %v", ok, err)
+	}
+	if ok, err := cachable.GVR(schema.GroupVersionResource{Group: "project.openshift.io", Version: "v1", Resource: "projects"}); err != nil || ok {
+		t.Errorf("expected projects not to be cachable, got %v, %v", ok, err)
+	}
+}
+
+func TestLoad_ResourceLists(t *testing.T) {
+	c, err := Load([]byte(resourceListsYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := disc.HasGVK(c.Discovery(), "policy/v1beta1", "PodDisruptionBudget")
+	if err != nil || !ok {
+		t.Errorf("expected policy/v1beta1 PodDisruptionBudget to be served, got %v, %v", ok, err)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	if _, err := Load([]byte("resources:\n- groupVersion: v1\n  resources:\n  - name: pods\n")); err == nil {
+		t.Errorf("expected error for resource without kind")
+	}
+}
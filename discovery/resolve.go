@@ -0,0 +1,196 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"errors"
+	"strings"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+var errNoDiscoveryClient = errors.New("resource mapper was created without a discovery client")
+
+// ResourceInfo is the discovery info of a resource or one of its subresources.
+type ResourceInfo struct {
+	kmapi.ResourceID
+	// Subresource is the name of the subresource, eg, log for pods/log, if the
+	// info refers to a subresource.
+	Subresource  string
+	SingularName string
+	ShortNames   []string
+	Categories   []string
+	// Verbs lists the verbs supported by the resource, or by the subresource if set.
+	Verbs []string
+	// Subresources maps the name of each subresource of the resource to its verbs.
+	Subresources map[string][]string
+}
+
+// Supports returns true if the resource, or the subresource if set, supports the verb.
+func (r ResourceInfo) Supports(verb string) bool {
+	for _, v := range r.Verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *resourcemapper) ResourceInfoForGVR(gvr schema.GroupVersionResource) (*ResourceInfo, error) {
+	if m.client == nil {
+		return nil, errNoDiscoveryClient
+	}
+
+	rid, err := m.ResourceIDForGVR(gvr)
+	if err != nil {
+		return nil, err
+	}
+	list, err := m.client.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return nil, err
+	}
+
+	info := ResourceInfo{
+		ResourceID:   *rid,
+		Subresources: map[string][]string{},
+	}
+	found := false
+	for _, r := range list.APIResources {
+		if r.Name == gvr.Resource {
+			found = true
+			info.SingularName = r.SingularName
+			info.ShortNames = r.ShortNames
+			info.Categories = r.Categories
+			info.Verbs = r.Verbs
+		} else if sub, ok := strings.CutPrefix(r.Name, gvr.Resource+"/"); ok {
+			info.Subresources[sub] = r.Verbs
+		}
+	}
+	if !found {
+		return nil, &meta.NoResourceMatchError{PartialResource: gvr}
+	}
+	return &info, nil
+}
+
+func (m *resourcemapper) Resolve(arg string) ([]ResourceInfo, error) {
+	if m.client == nil {
+		return nil, errNoDiscoveryClient
+	}
+
+	resource, subresource, _ := strings.Cut(strings.ToLower(arg), "/")
+	lists, err := m.client.ServerPreferredResources()
+	if discovery.IsGroupDiscoveryFailedError(err) {
+		klog.Errorf("Skipping failed API Groups: %v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var gvrs []schema.GroupVersionResource
+	fullySpecifiedGVR, gr := schema.ParseResourceArg(resource)
+	if fullySpecifiedGVR != nil {
+		if gvr, err := m.mapper.ResourceFor(*fullySpecifiedGVR); err == nil {
+			gvrs = append(gvrs, gvr)
+		}
+	}
+	if len(gvrs) == 0 {
+		if expanded, ok := expandResource(lists, gr); ok {
+			gvr, err := m.mapper.ResourceFor(expanded.WithVersion(""))
+			if err != nil {
+				return nil, err
+			}
+			gvrs = append(gvrs, gvr)
+		} else if gr.Group == "" && subresource == "" {
+			gvrs = expandCategory(lists, gr.Resource)
+		}
+	}
+	if len(gvrs) == 0 {
+		return nil, &meta.NoResourceMatchError{PartialResource: gr.WithVersion("")}
+	}
+
+	out := make([]ResourceInfo, 0, len(gvrs))
+	for _, gvr := range gvrs {
+		info, err := m.ResourceInfoForGVR(gvr)
+		if err != nil {
+			return nil, err
+		}
+		if subresource != "" {
+			verbs, ok := info.Subresources[subresource]
+			if !ok {
+				return nil, &meta.NoResourceMatchError{PartialResource: gvr.GroupVersion().WithResource(gvr.Resource + "/" + subresource)}
+			}
+			info.Subresource = subresource
+			info.Verbs = verbs
+		}
+		out = append(out, *info)
+	}
+	return out, nil
+}
+
+// expandResource finds the resource whose plural name, singular name, short name or
+// kind matches gr.Resource. If gr.Group is set, only resources of that group match.
+func expandResource(lists []*metav1.APIResourceList, gr schema.GroupResource) (schema.GroupResource, bool) {
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || (gr.Group != "" && gr.Group != gv.Group) {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.ContainsRune(r.Name, '/') {
+				continue
+			}
+			if r.Name == gr.Resource ||
+				r.SingularName == gr.Resource ||
+				strings.ToLower(r.Kind) == gr.Resource ||
+				contains(r.ShortNames, gr.Resource) {
+				return schema.GroupResource{Group: gv.Group, Resource: r.Name}, true
+			}
+		}
+	}
+	return schema.GroupResource{}, false
+}
+
+// expandCategory returns the preferred version of the resources in the category.
+func expandCategory(lists []*metav1.APIResourceList, category string) []schema.GroupVersionResource {
+	var out []schema.GroupVersionResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if !strings.ContainsRune(r.Name, '/') && contains(r.Categories, category) {
+				out = append(out, gv.WithResource(r.Name))
+			}
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newTestResourceMapper() ResourceResolver {
+	return NewResourceMapperForDiscovery(&fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}, Categories: []string{"all"}, Verbs: []string{"get", "list", "delete"}},
+						{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+						{Name: "pods/status", Kind: "Pod", Namespaced: true, Verbs: []string{"get", "patch", "update"}},
+					},
+				},
+				{
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}, Categories: []string{"all"}, Verbs: []string{"get", "list"}},
+						{Name: "deployments/scale", Kind: "Scale", Group: "autoscaling", Version: "v1", Namespaced: true, Verbs: []string{"get", "patch", "update"}},
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestResourceMapper_Resolve(t *testing.T) {
+	m := newTestResourceMapper()
+	cases := []struct {
+		arg         string
+		expected    []string
+		subresource string
+		verbs       []string
+	}{
+		{arg: "deploy", expected: []string{"apps/v1, Resource=deployments"}, verbs: []string{"get", "list"}},
+		{arg: "deployment", expected: []string{"apps/v1, Resource=deployments"}, verbs: []string{"get", "list"}},
+		{arg: "Deployment", expected: []string{"apps/v1, Resource=deployments"}, verbs: []string{"get", "list"}},
+		{arg: "deployments.apps", expected: []string{"apps/v1, Resource=deployments"}, verbs: []string{"get", "list"}},
+		{arg: "deployments.v1.apps", expected: []string{"apps/v1, Resource=deployments"}, verbs: []string{"get", "list"}},
+		{arg: "po/log", expected: []string{"/v1, Resource=pods"}, subresource: "log", verbs: []string{"get"}},
+		{arg: "deploy/scale", expected: []string{"apps/v1, Resource=deployments"}, subresource: "scale", verbs: []string{"get", "patch", "update"}},
+	}
+	for _, c := range cases {
+		t.Run(c.arg, func(t *testing.T) {
+			infos, err := m.Resolve(c.arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gvrs []string
+			for _, info := range infos {
+				gvrs = append(gvrs, info.GroupVersionResource().String())
+			}
+			if !reflect.DeepEqual(gvrs, c.expected) {
+				t.Fatalf("expected %v, found %v", c.expected, gvrs)
+			}
+			if infos[0].Subresource != c.subresource || !reflect.DeepEqual(infos[0].Verbs, c.verbs) {
+				t.Errorf("unexpected info %+v", infos[0])
+			}
+		})
+	}
+
+	infos, err := m.Resolve("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Errorf("expected 2 resources in category all, found %d", len(infos))
+	}
+
+	for _, arg := range []string{"foo", "po/exec", "deployments.batch"} {
+		if _, err := m.Resolve(arg); !meta.IsNoMatchError(err) {
+			t.Errorf("expected no match error for %s, found %v", arg, err)
+		}
+	}
+}
+
+func TestResourceMapper_ResourceInfoForGVR(t *testing.T) {
+	m := newTestResourceMapper()
+	info, err := m.ResourceInfoForGVR(metav1.SchemeGroupVersion.WithResource("pods"))
+	if err == nil {
+		t.Fatalf("expected error for unknown group version, found %+v", info)
+	}
+
+	infos, err := m.Resolve("pods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = &infos[0]
+	if info.Kind != "Pod" || info.Scope != "Namespaced" || info.SingularName != "pod" || !info.Supports("delete") {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if expected := map[string][]string{"log": {"get"}, "status": {"get", "patch", "update"}}; !reflect.DeepEqual(info.Subresources, expected) {
+		t.Errorf("expected subresources %v, found %v", expected, info.Subresources)
+	}
+}
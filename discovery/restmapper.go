@@ -111,20 +111,49 @@ type ResourceMapper interface {
 	Reset()
 }
 
+// ResourceResolver is a ResourceMapper that can also resolve short names, categories and
+// subresources using discovery. The ResourceMapper returned by NewDynamicResourceMapper
+// implements it too.
+type ResourceResolver interface {
+	ResourceMapper
+	// ResourceInfoForGVR returns the discovery info of a resource including its subresources.
+	ResourceInfoForGVR(gvr schema.GroupVersionResource) (*ResourceInfo, error)
+	// Resolve resolves kubectl style resource arguments, eg, deploy, deployments.v1.apps,
+	// po/log or a category like all.
+	Resolve(arg string) ([]ResourceInfo, error)
+}
+
 type resourcemapper struct {
 	mapper meta.RESTMapper
+	// client is used to look up discovery info not available via the RESTMapper.
+	// It is nil if the resource mapper was created from a RESTMapper.
+	client discovery.CachedDiscoveryInterface
 
 	// Keeping this cache unbounded, since the # of unique GVKs will be at most few thousands
 	cache map[schema.GroupVersionKind]*kmapi.ResourceID
 	lock  sync.RWMutex
 }
 
-var _ ResourceMapper = &resourcemapper{}
+var _ ResourceResolver = &resourcemapper{}
 
 func NewResourceMapper(mapper meta.RESTMapper) ResourceMapper {
 	return &resourcemapper{mapper: mapper, cache: map[schema.GroupVersionKind]*kmapi.ResourceID{}}
 }
 
+// NewResourceMapperForDiscovery returns a ResourceResolver that uses the given discovery client.
+// Unlike NewResourceMapper, it can also resolve short names, categories and subresources.
+func NewResourceMapperForDiscovery(client discovery.DiscoveryInterface) ResourceResolver {
+	cached, ok := client.(discovery.CachedDiscoveryInterface)
+	if !ok {
+		cached = memory.NewMemCacheClient(client)
+	}
+	return &resourcemapper{
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(cached),
+		client: cached,
+		cache:  map[schema.GroupVersionKind]*kmapi.ResourceID{},
+	}
+}
+
 func NewDynamicResourceMapper(cfg *rest.Config) (ResourceMapper, error) {
 	hc, err := rest.HTTPClientFor(cfg)
 	if err != nil {
@@ -134,7 +163,15 @@ func NewDynamicResourceMapper(cfg *rest.Config) (ResourceMapper, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &resourcemapper{mapper: mapper, cache: map[schema.GroupVersionKind]*kmapi.ResourceID{}}, nil
+	client, err := discovery.NewDiscoveryClientForConfigAndClient(cfg, hc)
+	if err != nil {
+		return nil, err
+	}
+	return &resourcemapper{
+		mapper: mapper,
+		client: memory.NewMemCacheClient(client),
+		cache:  map[schema.GroupVersionKind]*kmapi.ResourceID{},
+	}, nil
 }
 
 func (m *resourcemapper) ResourceIDForGVK(gvk schema.GroupVersionKind) (*kmapi.ResourceID, error) {
@@ -266,4 +303,7 @@ func (m *resourcemapper) Reset() {
 	if c, ok := m.mapper.(ResetCache); ok {
 		c.Reset()
 	}
+	if m.client != nil {
+		m.client.Invalidate()
+	}
 }
@@ -0,0 +1,125 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"fmt"
+
+	kutil "kmodules.xyz/client-go"
+	"kmodules.xyz/client-go/meta"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// CreateOrApply creates or updates the object using server-side apply. The desired object
+// must contain only the fields managed by opts.FieldManager. Set opts.DryRun to
+// []string{metav1.DryRunAll} to preview the changes and opts.Force to take ownership of
+// fields managed by other field managers.
+//
+// It returns the applied object, the verb describing the change and a human readable
+// diff between the current and the applied object. The diff is empty if nothing changed.
+func CreateOrApply(
+	ctx context.Context,
+	c dynamic.Interface,
+	gvr schema.GroupVersionResource,
+	desired *unstructured.Unstructured,
+	opts metav1.ApplyOptions,
+) (*unstructured.Unstructured, kutil.VerbType, string, error) {
+	return apply(ctx, c, gvr, desired, opts, false)
+}
+
+// ApplyStatus updates the status subresource of the object using server-side apply.
+// It returns the applied object, the verb describing the change and a human readable
+// diff between the current and the applied object.
+func ApplyStatus(
+	ctx context.Context,
+	c dynamic.Interface,
+	gvr schema.GroupVersionResource,
+	desired *unstructured.Unstructured,
+	opts metav1.ApplyOptions,
+) (*unstructured.Unstructured, kutil.VerbType, string, error) {
+	return apply(ctx, c, gvr, desired, opts, true)
+}
+
+func apply(
+	ctx context.Context,
+	c dynamic.Interface,
+	gvr schema.GroupVersionResource,
+	desired *unstructured.Unstructured,
+	opts metav1.ApplyOptions,
+	status bool,
+) (*unstructured.Unstructured, kutil.VerbType, string, error) {
+	if opts.FieldManager == "" {
+		return nil, kutil.VerbUnchanged, "", fmt.Errorf("field manager is required to apply %s %s/%s", gvr.String(), desired.GetNamespace(), desired.GetName())
+	}
+
+	var ri dynamic.ResourceInterface
+	if desired.GetNamespace() == "" {
+		ri = c.Resource(gvr)
+	} else {
+		ri = c.Resource(gvr).Namespace(desired.GetNamespace())
+	}
+
+	cur, err := ri.Get(ctx, desired.GetName(), metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		if status {
+			return nil, kutil.VerbUnchanged, "", err
+		}
+		cur = nil
+	} else if err != nil {
+		return nil, kutil.VerbUnchanged, "", err
+	}
+
+	var out *unstructured.Unstructured
+	if status {
+		klog.V(3).Infof("Applying status of %s %s/%s.", gvr.String(), desired.GetNamespace(), desired.GetName())
+		out, err = ri.ApplyStatus(ctx, desired.GetName(), desired, opts)
+	} else {
+		klog.V(3).Infof("Applying %s %s/%s.", gvr.String(), desired.GetNamespace(), desired.GetName())
+		out, err = ri.Apply(ctx, desired.GetName(), desired, opts)
+	}
+	if err != nil {
+		return nil, kutil.VerbUnchanged, "", err
+	}
+
+	if cur == nil {
+		diff, err := meta.JsonDiff(map[string]interface{}{}, withoutServerFields(out))
+		return out, kutil.VerbCreated, diff, err
+	}
+	before, after := withoutServerFields(cur), withoutServerFields(out)
+	if meta.Equal(before, after) {
+		return out, kutil.VerbUnchanged, "", nil
+	}
+	diff, err := meta.JsonDiff(before, after)
+	return out, kutil.VerbPatched, diff, err
+}
+
+// withoutServerFields returns the content of the object without the fields
+// that are updated by the API server on every write.
+func withoutServerFields(u *unstructured.Unstructured) map[string]interface{} {
+	out := u.DeepCopy()
+	out.SetResourceVersion("")
+	out.SetManagedFields(nil)
+	out.SetGeneration(0)
+	return out.Object
+}
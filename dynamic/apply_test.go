@@ -0,0 +1,163 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kutil "kmodules.xyz/client-go"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var configMapGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// newApplyClient returns a fake dynamic client that handles apply patches as
+// merge patches and creates missing objects, like the API server does.
+func newApplyClient(objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	c := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		configMapGVR: "ConfigMapList",
+	}, objs...)
+	c.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		pa := action.(clienttesting.PatchAction)
+		if pa.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		tracker := c.Tracker()
+		cur, err := tracker.Get(pa.GetResource(), pa.GetNamespace(), pa.GetName())
+		if kerr.IsNotFound(err) {
+			if pa.GetSubresource() != "" {
+				return true, nil, err
+			}
+			var u unstructured.Unstructured
+			if err := json.Unmarshal(pa.GetPatch(), &u.Object); err != nil {
+				return true, nil, err
+			}
+			u.SetResourceVersion("1")
+			return true, &u, tracker.Create(pa.GetResource(), &u, pa.GetNamespace())
+		} else if err != nil {
+			return true, nil, err
+		}
+		data, err := json.Marshal(cur)
+		if err != nil {
+			return true, nil, err
+		}
+		data, err = jsonpatch.MergePatch(data, pa.GetPatch())
+		if err != nil {
+			return true, nil, err
+		}
+		var u unstructured.Unstructured
+		if err := json.Unmarshal(data, &u.Object); err != nil {
+			return true, nil, err
+		}
+		u.SetResourceVersion(cur.(*unstructured.Unstructured).GetResourceVersion() + "1")
+		return true, &u, tracker.Update(pa.GetResource(), &u, pa.GetNamespace())
+	})
+	return c
+}
+
+func newDemoConfigMap(data map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"name":      "demo",
+		},
+	}}
+	if data != nil {
+		u.Object["data"] = data
+	}
+	return u
+}
+
+func TestCreateOrApply(t *testing.T) {
+	ctx := context.TODO()
+	c := newApplyClient()
+	opts := metav1.ApplyOptions{FieldManager: "test-manager"}
+
+	out, vt, diff, err := CreateOrApply(ctx, c, configMapGVR, newDemoConfigMap(map[string]interface{}{"a": "1"}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt != kutil.VerbCreated {
+		t.Errorf("expected %s, got %s", kutil.VerbCreated, vt)
+	}
+	if !strings.Contains(diff, `"a"`) {
+		t.Errorf("expected diff to contain the created data, got %s", diff)
+	}
+	if v, _, _ := unstructured.NestedString(out.Object, "data", "a"); v != "1" {
+		t.Errorf("unexpected applied object %v", out.Object)
+	}
+
+	_, vt, diff, err = CreateOrApply(ctx, c, configMapGVR, newDemoConfigMap(map[string]interface{}{"a": "1"}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt != kutil.VerbUnchanged || diff != "" {
+		t.Errorf("expected unchanged object, got %s with diff %s", vt, diff)
+	}
+
+	_, vt, diff, err = CreateOrApply(ctx, c, configMapGVR, newDemoConfigMap(map[string]interface{}{"a": "2"}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt != kutil.VerbPatched {
+		t.Errorf("expected %s, got %s", kutil.VerbPatched, vt)
+	}
+	if !strings.Contains(diff, `"1"`) || !strings.Contains(diff, `"2"`) {
+		t.Errorf("expected diff to contain the old and new value, got %s", diff)
+	}
+
+	if _, _, _, err = CreateOrApply(ctx, c, configMapGVR, newDemoConfigMap(nil), metav1.ApplyOptions{}); err == nil {
+		t.Errorf("expected error for missing field manager")
+	}
+}
+
+func TestApplyStatus(t *testing.T) {
+	ctx := context.TODO()
+	opts := metav1.ApplyOptions{FieldManager: "test-manager"}
+
+	desired := newDemoConfigMap(nil)
+	desired.Object["status"] = map[string]interface{}{"phase": "Ready"}
+	if _, _, _, err := ApplyStatus(ctx, newApplyClient(), configMapGVR, desired, opts); !kerr.IsNotFound(err) {
+		t.Errorf("expected NotFound for missing object, got %v", err)
+	}
+
+	c := newApplyClient(newDemoConfigMap(map[string]interface{}{"a": "1"}))
+	out, vt, diff, err := ApplyStatus(ctx, c, configMapGVR, desired, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt != kutil.VerbPatched || !strings.Contains(diff, "Ready") {
+		t.Errorf("expected patched status, got %s with diff %s", vt, diff)
+	}
+	if phase, _, _ := unstructured.NestedString(out.Object, "status", "phase"); phase != "Ready" {
+		t.Errorf("unexpected applied object %v", out.Object)
+	}
+}
@@ -18,16 +18,12 @@ package conditions
 
 import (
 	"context"
-	"reflect"
-	"time"
 
 	kmapi "kmodules.xyz/client-go/api/v1"
 	"kmodules.xyz/client-go/conditions"
 
-	"github.com/mitchellh/mapstructure"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 )
@@ -64,15 +60,7 @@ func (do *DynamicOptions) SetCondition(newCond kmapi.Condition) error {
 	}
 	conds = conditions.SetCondition(conds, newCond)
 
-	unstrConds := make([]interface{}, len(conds))
-	for i := range conds {
-		cond, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&conds[i])
-		if err != nil {
-			return err
-		}
-		unstrConds[i] = cond
-	}
-	err = unstructured.SetNestedField(res.Object, unstrConds, "status", "conditions")
+	err = conditions.WriteUnstructuredConditions(res, conds)
 	if err != nil {
 		return err
 	}
@@ -87,15 +75,7 @@ func (do *DynamicOptions) RemoveCondition(condType string) error {
 	}
 	conds = conditions.RemoveCondition(conds, condType)
 
-	unstrConds := make([]interface{}, len(conds))
-	for i := range conds {
-		cond, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&conds[i])
-		if err != nil {
-			return err
-		}
-		unstrConds[i] = cond
-	}
-	err = unstructured.SetNestedField(res.Object, unstrConds, "status", "conditions")
+	err = conditions.WriteUnstructuredConditions(res, conds)
 	if err != nil {
 		return err
 	}
@@ -116,73 +96,23 @@ func (do *DynamicOptions) ReadConditions() (*unstructured.Unstructured, []kmapi.
 	if err != nil {
 		return nil, nil, err
 	}
-	unstrConds, ok, err := unstructured.NestedFieldNoCopy(resp.Object, "status", "conditions")
-	if err != nil {
-		return nil, nil, err
-	}
-	if !ok {
-		return resp, nil, nil
-	}
-	var conditions []kmapi.Condition
-
-	config := &mapstructure.DecoderConfig{
-		Metadata:   nil,
-		Result:     &conditions,
-		DecodeHook: stringToTimeHookFunc(time.RFC3339),
-	}
-	decoder, err := mapstructure.NewDecoder(config)
-	if err != nil {
-		return nil, nil, err
-	}
-	err = decoder.Decode(unstrConds)
+	conds, err := conditions.ReadUnstructuredConditions(resp)
 	if err != nil {
 		return nil, nil, err
 	}
-	return resp, conditions, err
+	return resp, conds, nil
 }
 
-func (do *DynamicOptions) UpdateConditions(conditions []kmapi.Condition) error {
+func (do *DynamicOptions) UpdateConditions(conds []kmapi.Condition) error {
 	obj, err := do.Client.Resource(do.GVR).Namespace(do.Namespace).Get(context.TODO(), do.Name, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
-	unstrConds := make([]interface{}, len(conditions))
-	for i := range conditions {
-		cond, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&conditions[i])
-		if err != nil {
-			return err
-		}
-		unstrConds[i] = cond
-	}
-
-	err = unstructured.SetNestedField(obj.Object, unstrConds, "status", "conditions")
+	err = conditions.WriteUnstructuredConditions(obj, conds)
 	if err != nil {
 		return err
 	}
 	_, err = do.Client.Resource(do.GVR).Namespace(do.Namespace).UpdateStatus(context.TODO(), obj, metav1.UpdateOptions{})
 	return err
 }
-
-// stringToTimeHookFunc returns a DecodeHookFunc that converts
-// strings to time.Time.
-func stringToTimeHookFunc(layout string) mapstructure.DecodeHookFunc {
-	return func(
-		f reflect.Type,
-		t reflect.Type,
-		data interface{},
-	) (interface{}, error) {
-		if f.Kind() != reflect.String {
-			return data, nil
-		}
-		if t != reflect.TypeOf(metav1.Time{}) {
-			return data, nil
-		}
-		// Convert it by parsing
-		out, err := time.Parse(layout, data.(string))
-		if err != nil {
-			return nil, err
-		}
-		return metav1.NewTime(out), nil
-	}
-}
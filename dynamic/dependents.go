@@ -0,0 +1,409 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	v1 "kmodules.xyz/client-go/core/v1"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Dependent is an object that has one or more owner references.
+type Dependent struct {
+	GVR        schema.GroupVersionResource
+	Namespaced bool
+	Object     *metav1.PartialObjectMetadata
+}
+
+// Orphan is a Dependent whose owners no longer exist.
+type Orphan struct {
+	Dependent
+	MissingOwners []metav1.OwnerReference
+}
+
+// DependentsManager discovers the dependents of owners across all the namespaced and cluster
+// scoped resources served by the cluster and adopts or releases them in bulk.
+type DependentsManager struct {
+	disc discovery.DiscoveryInterface
+	mc   metadata.Interface
+}
+
+// NewDependentsManager returns a DependentsManager that uses the given discovery and metadata clients.
+func NewDependentsManager(disc discovery.DiscoveryInterface, mc metadata.Interface) *DependentsManager {
+	return &DependentsManager{disc: disc, mc: mc}
+}
+
+// NewDependentsManagerForConfig returns a DependentsManager for the given rest config.
+func NewDependentsManagerForConfig(config *rest.Config) (*DependentsManager, error) {
+	disc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mc, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewDependentsManager(disc, mc), nil
+}
+
+type listableResource struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+	patchable  bool
+}
+
+// IncompleteResultError is returned when some resources could not be listed, so the
+// existence of owners in those resources can not be verified.
+type IncompleteResultError struct {
+	Skipped []string
+}
+
+func (e *IncompleteResultError) Error() string {
+	return "results are incomplete, skipped " + strings.Join(e.Skipped, ", ")
+}
+
+// resources returns the preferred version of every resource that can be listed. Groups that
+// can not be discovered, eg, unavailable aggregated apis, are returned as skipped.
+func (m *DependentsManager) resources() ([]listableResource, []string, error) {
+	var skipped []string
+	lists, err := m.disc.ServerPreferredResources()
+	if err != nil {
+		var gdf *discovery.ErrGroupDiscoveryFailed
+		if !errors.As(err, &gdf) {
+			return nil, nil, err
+		}
+		klog.Warningf("skipping api groups that failed discovery: %v", err)
+		for gv := range gdf.Groups {
+			skipped = append(skipped, gv.String())
+		}
+	}
+
+	var out []listableResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range list.APIResources {
+			if strings.ContainsRune(r.Name, '/') {
+				continue // skip subresource
+			}
+			verbs := sets.New[string](r.Verbs...)
+			if !verbs.Has("list") {
+				continue
+			}
+			out = append(out, listableResource{
+				gvr:        gv.WithResource(r.Name),
+				namespaced: r.Namespaced,
+				patchable:  verbs.Has("patch"),
+			})
+		}
+	}
+	return out, skipped, nil
+}
+
+// visit lists the metadata of all objects of every resource. If namespace is set, only the
+// objects in that namespace and the cluster scoped objects are visited. It returns the
+// groups and resources that were skipped because they could not be discovered or listed.
+func (m *DependentsManager) visit(ctx context.Context, namespace string, fn func(r listableResource, obj *metav1.PartialObjectMetadata)) ([]string, error) {
+	resources, skipped, err := m.resources()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, r := range resources {
+		var ri metadata.ResourceInterface
+		if r.namespaced {
+			ri = m.mc.Resource(r.gvr).Namespace(namespace)
+		} else {
+			ri = m.mc.Resource(r.gvr)
+		}
+		list, err := ri.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if kerr.IsNotFound(err) || kerr.IsForbidden(err) || kerr.IsMethodNotSupported(err) {
+				klog.V(3).Infof("skipping %s: %v", r.gvr, err)
+				skipped = append(skipped, r.gvr.String())
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		for i := range list.Items {
+			fn(r, &list.Items[i])
+		}
+	}
+	return skipped, utilerrors.NewAggregate(errs)
+}
+
+// FindDependents returns all objects that have an owner reference to the given owner and
+// can be patched. Dependents of a namespaced owner are only searched in the namespace of
+// the owner. Resources that can not be listed are skipped.
+func (m *DependentsManager) FindDependents(ctx context.Context, owner metav1.Object) ([]Dependent, error) {
+	var out []Dependent
+	_, err := m.visit(ctx, owner.GetNamespace(), func(r listableResource, obj *metav1.PartialObjectMetadata) {
+		if !r.patchable {
+			return
+		}
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.UID == owner.GetUID() {
+				out = append(out, Dependent{GVR: r.gvr, Namespaced: r.namespaced, Object: obj})
+				break
+			}
+		}
+	})
+	return out, err
+}
+
+// FindOrphans returns the objects with owner references to objects that no longer exist.
+// If namespace is set, only the dependents in that namespace and cluster scoped dependents
+// are reported. Owners are looked up in every resource that can be listed; if any group or
+// resource can not be listed, an *IncompleteResultError is returned instead of orphans.
+func (m *DependentsManager) FindOrphans(ctx context.Context, namespace string) ([]Orphan, error) {
+	uids := sets.New[types.UID]()
+	var dependents []Dependent
+	skipped, err := m.visit(ctx, namespace, func(r listableResource, obj *metav1.PartialObjectMetadata) {
+		uids.Insert(obj.GetUID())
+		if r.patchable && len(obj.GetOwnerReferences()) > 0 {
+			dependents = append(dependents, Dependent{GVR: r.gvr, Namespaced: r.namespaced, Object: obj})
+		}
+	})
+	// owners can not be verified reliably with partial results
+	if err != nil {
+		return nil, err
+	}
+	if len(skipped) > 0 {
+		return nil, &IncompleteResultError{Skipped: skipped}
+	}
+
+	// The lists are not a consistent snapshot, eg, an owner may have been created after its
+	// resource was listed, so missing owners are confirmed with a live request.
+	oc := m.newOwnerChecker()
+	var out []Orphan
+	for _, d := range dependents {
+		var missing []metav1.OwnerReference
+		for _, ref := range d.Object.GetOwnerReferences() {
+			if uids.Has(ref.UID) {
+				continue
+			}
+			gone, err := oc.isMissing(ctx, d.Object.GetNamespace(), ref)
+			if err != nil {
+				return nil, err
+			}
+			if gone {
+				missing = append(missing, ref)
+			}
+		}
+		if len(missing) > 0 {
+			out = append(out, Orphan{Dependent: d, MissingOwners: missing})
+		}
+	}
+	return out, nil
+}
+
+// Adopt adds the owner reference to all the dependents.
+func (m *DependentsManager) Adopt(ctx context.Context, dependents []Dependent, owner *metav1.OwnerReference) error {
+	return m.patchOwnerReferences(ctx, dependents, func(obj metav1.Object) {
+		v1.EnsureOwnerReference(obj, owner)
+	})
+}
+
+// Release removes the owner references to the given owner from all the dependents.
+func (m *DependentsManager) Release(ctx context.Context, dependents []Dependent, owner metav1.Object) error {
+	return m.patchOwnerReferences(ctx, dependents, func(obj metav1.Object) {
+		v1.RemoveOwnerReference(obj, owner)
+	})
+}
+
+// ReleaseMissingOwners removes the owner references to owners that no longer exist from the orphans.
+// Each missing owner is confirmed again with a live request before its reference is removed.
+func (m *DependentsManager) ReleaseMissingOwners(ctx context.Context, orphans []Orphan) error {
+	oc := m.newOwnerChecker()
+	var errs []error
+	for _, o := range orphans {
+		missing := sets.New[types.UID]()
+		for _, ref := range o.MissingOwners {
+			gone, err := oc.isMissing(ctx, o.Object.GetNamespace(), ref)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if gone {
+				missing.Insert(ref.UID)
+			}
+		}
+		if missing.Len() == 0 {
+			continue
+		}
+		err := m.patchOwnerReferences(ctx, []Dependent{o.Dependent}, func(obj metav1.Object) {
+			refs := obj.GetOwnerReferences()
+			out := make([]metav1.OwnerReference, 0, len(refs))
+			for _, ref := range refs {
+				if !missing.Has(ref.UID) {
+					out = append(out, ref)
+				}
+			}
+			obj.SetOwnerReferences(out)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// ownerChecker looks up owners with live requests and caches the results.
+type ownerChecker struct {
+	m       *DependentsManager
+	lists   map[string]*metav1.APIResourceList
+	missing map[types.UID]bool
+}
+
+func (m *DependentsManager) newOwnerChecker() *ownerChecker {
+	return &ownerChecker{
+		m:       m,
+		lists:   map[string]*metav1.APIResourceList{},
+		missing: map[types.UID]bool{},
+	}
+}
+
+// isMissing returns true if the owner referenced by ref is not found or has a different
+// UID. Owners of namespaced dependents are looked up in the namespace of the dependent.
+// Owners whose kind is not served can not be looked up and are not reported as missing.
+func (c *ownerChecker) isMissing(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+	if gone, ok := c.missing[ref.UID]; ok {
+		return gone, nil
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false, err
+	}
+	list, ok := c.lists[ref.APIVersion]
+	if !ok {
+		list, err = c.m.disc.ServerResourcesForGroupVersion(ref.APIVersion)
+		if err != nil && !kerr.IsNotFound(err) && !errors.Is(err, memory.ErrCacheNotFound) {
+			return false, err
+		}
+		c.lists[ref.APIVersion] = list
+	}
+
+	var r *metav1.APIResource
+	if list != nil {
+		for i := range list.APIResources {
+			if list.APIResources[i].Kind == ref.Kind && !strings.ContainsRune(list.APIResources[i].Name, '/') {
+				r = &list.APIResources[i]
+				break
+			}
+		}
+	}
+	if r == nil {
+		klog.V(3).Infof("skipping owner %s %s: kind is not served", ref.APIVersion, ref.Kind)
+		c.missing[ref.UID] = false
+		return false, nil
+	}
+
+	var ri metadata.ResourceInterface
+	if r.Namespaced {
+		ri = c.m.mc.Resource(gv.WithResource(r.Name)).Namespace(namespace)
+	} else {
+		ri = c.m.mc.Resource(gv.WithResource(r.Name))
+	}
+	obj, err := ri.Get(ctx, ref.Name, metav1.GetOptions{})
+	switch {
+	case kerr.IsNotFound(err):
+		c.missing[ref.UID] = true
+	case err != nil:
+		return false, err
+	default:
+		c.missing[ref.UID] = obj.GetUID() != ref.UID
+	}
+	return c.missing[ref.UID], nil
+}
+
+func (m *DependentsManager) patchOwnerReferences(ctx context.Context, dependents []Dependent, transform func(obj metav1.Object)) error {
+	var errs []error
+	for _, d := range dependents {
+		mod := d.Object.DeepCopy()
+		transform(mod)
+		if equalOwnerReferences(d.Object.GetOwnerReferences(), mod.GetOwnerReferences()) {
+			continue
+		}
+
+		// the list is replaced as a whole, so the patch is guarded by the resourceVersion
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"ownerReferences": mod.GetOwnerReferences(),
+				"resourceVersion": d.Object.GetResourceVersion(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		var ri metadata.ResourceInterface
+		if d.Namespaced {
+			ri = m.mc.Resource(d.GVR).Namespace(d.Object.GetNamespace())
+		} else {
+			ri = m.mc.Resource(d.GVR)
+		}
+		klog.V(3).Infof("Patching owner references of %s %s/%s.", d.GVR.String(), d.Object.GetNamespace(), d.Object.GetName())
+		if _, err := ri.Patch(ctx, d.Object.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil && !kerr.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func equalOwnerReferences(x, y []metav1.OwnerReference) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i].UID != y[i].UID ||
+			x[i].APIVersion != y[i].APIVersion ||
+			x[i].Kind != y[i].Kind ||
+			x[i].Name != y[i].Name ||
+			!equalBoolPtr(x[i].Controller, y[i].Controller) ||
+			!equalBoolPtr(x[i].BlockOwnerDeletion, y[i].BlockOwnerDeletion) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalBoolPtr(x, y *bool) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return *x == *y
+}
@@ -0,0 +1,286 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	discfake "kmodules.xyz/client-go/discovery/fake"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// Owners are served with get and list only, so they can be found but not patched.
+var dependentsCluster = discfake.MustLoad([]byte(`
+resources:
+- groupVersion: v1
+  resources:
+  - name: configmaps
+    kind: ConfigMap
+    namespaced: true
+  - name: secrets
+    kind: Secret
+    namespaced: true
+- groupVersion: example.com/v1
+  resources:
+  - name: owners
+    kind: Owner
+    namespaced: true
+    verbs: [get, list]
+`))
+
+var (
+	secretGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	ownerGVR  = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "owners"}
+)
+
+func newMetadataObject(gvr schema.GroupVersionResource, kind, name string, uid types.UID, owners ...*metav1.PartialObjectMetadata) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvr.GroupVersion().WithKind(kind))
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetUID(uid)
+	for _, o := range owners {
+		obj.OwnerReferences = append(obj.OwnerReferences, metav1.OwnerReference{
+			APIVersion: o.APIVersion,
+			Kind:       o.Kind,
+			Name:       o.Name,
+			UID:        o.UID,
+		})
+	}
+	return obj
+}
+
+type dependentsFixture struct {
+	mc     *metadatafake.FakeMetadataClient
+	m      *DependentsManager
+	owner  *metav1.PartialObjectMetadata
+	secret *metav1.PartialObjectMetadata
+}
+
+func newDependentsFixture(t *testing.T) *dependentsFixture {
+	t.Helper()
+	owner := newMetadataObject(ownerGVR, "Owner", "owner", "owner-uid")
+	secret := newMetadataObject(secretGVR, "Secret", "token", "secret-uid")
+	gone := newMetadataObject(ownerGVR, "Owner", "gone", "gone-uid")
+
+	scheme := metadatafake.NewTestScheme()
+	_ = metav1.AddMetaToScheme(scheme)
+	mc := metadatafake.NewSimpleMetadataClient(scheme)
+	for _, x := range []struct {
+		gvr schema.GroupVersionResource
+		obj *metav1.PartialObjectMetadata
+	}{
+		{ownerGVR, owner},
+		{secretGVR, secret},
+		{configMapGVR, newMetadataObject(configMapGVR, "ConfigMap", "a", "a-uid", owner)},
+		{configMapGVR, newMetadataObject(configMapGVR, "ConfigMap", "b", "b-uid", secret)},
+		{configMapGVR, newMetadataObject(configMapGVR, "ConfigMap", "c", "c-uid", gone, owner)},
+	} {
+		if err := mc.Tracker().Create(x.gvr, x.obj, x.obj.Namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &dependentsFixture{
+		mc:     mc,
+		m:      NewDependentsManager(dependentsCluster.Discovery(), mc),
+		owner:  owner,
+		secret: secret,
+	}
+}
+
+func (f *dependentsFixture) ownerUIDs(t *testing.T, name string) []types.UID {
+	t.Helper()
+	obj, err := f.mc.Resource(configMapGVR).Namespace("default").Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uids []types.UID
+	for _, ref := range obj.OwnerReferences {
+		uids = append(uids, ref.UID)
+	}
+	return uids
+}
+
+func dependentNames(dependents []Dependent) []string {
+	var names []string
+	for _, d := range dependents {
+		names = append(names, d.Object.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDependentsManager_AdoptRelease(t *testing.T) {
+	ctx := context.TODO()
+	f := newDependentsFixture(t)
+
+	dependents, err := f.m.FindDependents(ctx, f.owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := dependentNames(dependents); !equalStrings(names, []string{"a", "c"}) {
+		t.Fatalf("expected dependents [a c], got %v", names)
+	}
+
+	adopter := &metav1.OwnerReference{APIVersion: "v1", Kind: "Secret", Name: "token", UID: f.secret.UID}
+	if err := f.m.Adopt(ctx, dependents, adopter); err != nil {
+		t.Fatal(err)
+	}
+	if uids := f.ownerUIDs(t, "a"); !equalUIDs(uids, []types.UID{"owner-uid", "secret-uid"}) {
+		t.Errorf("expected a to be adopted, got owners %v", uids)
+	}
+
+	dependents, err = f.m.FindDependents(ctx, f.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := dependentNames(dependents); !equalStrings(names, []string{"a", "b", "c"}) {
+		t.Fatalf("expected dependents [a b c], got %v", names)
+	}
+	if err := f.m.Release(ctx, dependents, f.secret); err != nil {
+		t.Fatal(err)
+	}
+	if uids := f.ownerUIDs(t, "b"); len(uids) != 0 {
+		t.Errorf("expected b to be released, got owners %v", uids)
+	}
+	if uids := f.ownerUIDs(t, "c"); !equalUIDs(uids, []types.UID{"gone-uid", "owner-uid"}) {
+		t.Errorf("expected other owners of c to be kept, got %v", uids)
+	}
+}
+
+func TestDependentsManager_ReleaseMissingOwners(t *testing.T) {
+	ctx := context.TODO()
+	f := newDependentsFixture(t)
+
+	// the owner is not patchable, but must still be found
+	orphans, err := f.m.FindOrphans(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0].Object.Name != "c" ||
+		len(orphans[0].MissingOwners) != 1 || orphans[0].MissingOwners[0].UID != "gone-uid" {
+		t.Fatalf("unexpected orphans %+v", orphans)
+	}
+
+	if err := f.m.ReleaseMissingOwners(ctx, orphans); err != nil {
+		t.Fatal(err)
+	}
+	if uids := f.ownerUIDs(t, "c"); !equalUIDs(uids, []types.UID{"owner-uid"}) {
+		t.Errorf("expected only the missing owner to be released, got %v", uids)
+	}
+}
+
+func TestDependentsManager_FindOrphansForbiddenOwner(t *testing.T) {
+	f := newDependentsFixture(t)
+	f.mc.PrependReactor("list", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kerr.NewForbidden(secretGVR.GroupResource(), "", errors.New("access denied"))
+	})
+
+	orphans, err := f.m.FindOrphans(context.TODO(), "default")
+	var ire *IncompleteResultError
+	if !errors.As(err, &ire) {
+		t.Fatalf("expected IncompleteResultError, got orphans %+v and error %v", orphans, err)
+	}
+	if !equalStrings(ire.Skipped, []string{secretGVR.String()}) {
+		t.Errorf("unexpected skipped resources %v", ire.Skipped)
+	}
+
+	// dependents can still be found on a best effort basis
+	dependents, err := f.m.FindDependents(context.TODO(), f.owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := dependentNames(dependents); !equalStrings(names, []string{"a", "c"}) {
+		t.Errorf("expected dependents [a c], got %v", names)
+	}
+}
+
+func TestDependentsManager_FindOrphansConfirmsOwners(t *testing.T) {
+	ctx := context.TODO()
+	f := newDependentsFixture(t)
+
+	// a dependent of an earlier owner with the same name
+	recreated := newMetadataObject(configMapGVR, "ConfigMap", "d", "d-uid", f.owner)
+	recreated.OwnerReferences[0].UID = "old-owner-uid"
+	if err := f.mc.Tracker().Create(configMapGVR, recreated, recreated.Namespace); err != nil {
+		t.Fatal(err)
+	}
+	// the owner is created after owners are listed, but before its dependents are listed
+	f.mc.PrependReactor("list", "owners", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, &metav1.List{}, nil
+	})
+
+	orphans, err := f.m.FindOrphans(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]types.UID{}
+	for _, o := range orphans {
+		for _, ref := range o.MissingOwners {
+			got[o.Object.Name] = append(got[o.Object.Name], ref.UID)
+		}
+	}
+	if len(got) != 2 || !equalUIDs(got["c"], []types.UID{"gone-uid"}) || !equalUIDs(got["d"], []types.UID{"old-owner-uid"}) {
+		t.Fatalf("unexpected orphans %v", got)
+	}
+
+	// owners are confirmed again before they are released
+	stale := []Orphan{{
+		Dependent:     Dependent{GVR: configMapGVR, Namespaced: true, Object: newMetadataObject(configMapGVR, "ConfigMap", "a", "a-uid", f.owner)},
+		MissingOwners: []metav1.OwnerReference{{APIVersion: f.owner.APIVersion, Kind: f.owner.Kind, Name: f.owner.Name, UID: f.owner.UID}},
+	}}
+	if err := f.m.ReleaseMissingOwners(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	if uids := f.ownerUIDs(t, "a"); !equalUIDs(uids, []types.UID{"owner-uid"}) {
+		t.Errorf("expected existing owner to be kept, got %v", uids)
+	}
+}
+
+func equalStrings(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalUIDs(x, y []types.UID) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
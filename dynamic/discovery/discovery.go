@@ -22,9 +22,11 @@ import (
 	"sync"
 	"time"
 
+	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 )
 
@@ -54,10 +56,30 @@ type ResourceMap struct {
 	mutex         sync.RWMutex
 	groupVersions map[string]groupVersionEntry
 
+	// refreshMutex serializes full and targeted refreshes, so that a slow full refresh
+	// can't overwrite the result of a targeted refresh with stale data.
+	refreshMutex sync.Mutex
+
+	subscribersMutex sync.Mutex
+	subscribers      map[int]ResourceChangeFunc
+	nextSubscriberID int
+
 	discoveryClient discovery.DiscoveryInterface
 	stopCh, doneCh  chan struct{}
+	queue           workqueue.RateLimitingInterface
+	workerDoneCh    chan struct{}
+}
+
+// ResourceChange lists the resources added or removed by a refresh of the ResourceMap.
+// Subresources are not included.
+type ResourceChange struct {
+	Added   []schema.GroupVersionResource
+	Removed []schema.GroupVersionResource
 }
 
+// ResourceChangeFunc is called after a refresh that added or removed resources.
+type ResourceChangeFunc func(change ResourceChange)
+
 func (rm *ResourceMap) Get(apiVersion, resource string) (result *APIResource) {
 	rm.mutex.RLock()
 	defer rm.mutex.RUnlock()
@@ -80,11 +102,55 @@ func (rm *ResourceMap) GetKind(apiVersion, kind string) (result *APIResource) {
 	return gv.kinds[kind]
 }
 
+// Subscribe registers fn to be called whenever resources are added or removed. Callbacks are
+// called sequentially from the goroutine that refreshed the map. The returned function
+// removes the subscription.
+func (rm *ResourceMap) Subscribe(fn ResourceChangeFunc) (unsubscribe func()) {
+	rm.subscribersMutex.Lock()
+	defer rm.subscribersMutex.Unlock()
+
+	if rm.subscribers == nil {
+		rm.subscribers = map[int]ResourceChangeFunc{}
+	}
+	id := rm.nextSubscriberID
+	rm.nextSubscriberID++
+	rm.subscribers[id] = fn
+	return func() {
+		rm.subscribersMutex.Lock()
+		defer rm.subscribersMutex.Unlock()
+		delete(rm.subscribers, id)
+	}
+}
+
+func (rm *ResourceMap) notify(change ResourceChange) {
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return
+	}
+
+	// callbacks are called without holding the lock, so that they can unsubscribe
+	rm.subscribersMutex.Lock()
+	subscribers := make([]ResourceChangeFunc, 0, len(rm.subscribers))
+	for _, fn := range rm.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	rm.subscribersMutex.Unlock()
+
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
 func (rm *ResourceMap) refresh() {
+	rm.refreshMutex.Lock()
+	defer rm.refreshMutex.Unlock()
+	rm.refreshLocked()
+}
+
+func (rm *ResourceMap) refreshLocked() {
 	// Fetch all API Group-Versions and their resources from the server.
 	// We do this before acquiring the lock so we don't block readers.
 	klog.V(7).Info("Refreshing API discovery info")
-	_, groups, err := rm.discoveryClient.ServerGroupsAndResources()
+	groups, err := rm.serverGroupsAndResources()
 	if discovery.IsGroupDiscoveryFailedError(err) {
 		klog.Errorf("Skipping failed API Groups: %v", err)
 	} else if err != nil {
@@ -96,43 +162,137 @@ func (rm *ResourceMap) refresh() {
 	// by either Group-Version-Kind or Group-Version-Resource.
 	groupVersions := make(map[string]groupVersionEntry, len(groups))
 	for _, group := range groups {
-		gv, err := schema.ParseGroupVersion(group.GroupVersion)
+		groupVersions[group.GroupVersion] = newGroupVersionEntry(group)
+	}
+
+	// Replace the local cache.
+	rm.mutex.Lock()
+	old := rm.groupVersions
+	rm.groupVersions = groupVersions
+	rm.mutex.Unlock()
+
+	rm.notify(diff(old, groupVersions))
+}
+
+// serverGroupsAndResources uses aggregated discovery when supported by the discovery client
+// and the api server, so that all resources are fetched in at most two calls instead of
+// one call per group version.
+func (rm *ResourceMap) serverGroupsAndResources() ([]*metav1.APIResourceList, error) {
+	if ad, ok := rm.discoveryClient.(discovery.AggregatedDiscoveryInterface); ok {
+		_, resources, failedGVs, err := ad.GroupsAndMaybeResources()
 		if err != nil {
-			// This shouldn't happen because we get these values from the server.
-			panic(fmt.Errorf("received invalid GroupVersion from server: %v", err))
+			return nil, err
 		}
-		gve := groupVersionEntry{
-			resources: make(map[string]*APIResource, len(group.APIResources)),
-			kinds:     make(map[string]*APIResource, len(group.APIResources)),
-		}
-		for i := range group.APIResources {
-			apiResource := &APIResource{
-				APIResource: group.APIResources[i],
-				APIVersion:  group.GroupVersion,
-			}
-			// Materialize default values from the list into each entry.
-			if apiResource.Group == "" {
-				apiResource.Group = gv.Group
+		if resources != nil {
+			groups := make([]*metav1.APIResourceList, 0, len(resources))
+			for _, list := range resources {
+				groups = append(groups, list)
 			}
-			if apiResource.Version == "" {
-				apiResource.Version = gv.Version
-			}
-			gve.resources[apiResource.Name] = apiResource
-			// Remember how to map back from Kind to resource.
-			// This is different from what RESTMapper provides because we already know
-			// the full GroupVersionKind and just need the resource name.
-			// Make sure we don't choose a subresource like "pods/status".
-			if !strings.ContainsRune(apiResource.Name, '/') {
-				gve.kinds[apiResource.Kind] = apiResource
+			if len(failedGVs) > 0 {
+				return groups, &discovery.ErrGroupDiscoveryFailed{Groups: failedGVs}
 			}
+			return groups, nil
 		}
-		groupVersions[group.GroupVersion] = gve
+	}
+	_, groups, err := rm.discoveryClient.ServerGroupsAndResources()
+	return groups, err
+}
+
+// refreshGroupVersion fetches the resources of a single group version. The group version is
+// removed from the map if it is no longer served.
+func (rm *ResourceMap) refreshGroupVersion(groupVersion string) error {
+	rm.refreshMutex.Lock()
+	defer rm.refreshMutex.Unlock()
+
+	// A cached discovery client can only be invalidated as a whole.
+	if cd, ok := rm.discoveryClient.(discovery.CachedDiscoveryInterface); ok {
+		cd.Invalidate()
+		rm.refreshLocked()
+		return nil
+	}
+
+	klog.V(7).Infof("Refreshing API discovery info for %s", groupVersion)
+	var gve groupVersionEntry
+	list, err := rm.discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+	if err == nil {
+		gve = newGroupVersionEntry(list)
+	} else if !kerr.IsNotFound(err) {
+		return err
 	}
 
-	// Replace the local cache.
 	rm.mutex.Lock()
+	old := rm.groupVersions
+	groupVersions := make(map[string]groupVersionEntry, len(old)+1)
+	for k, v := range old {
+		groupVersions[k] = v
+	}
+	if gve.resources != nil {
+		groupVersions[groupVersion] = gve
+	} else {
+		delete(groupVersions, groupVersion)
+	}
 	rm.groupVersions = groupVersions
 	rm.mutex.Unlock()
+
+	rm.notify(diff(
+		map[string]groupVersionEntry{groupVersion: old[groupVersion]},
+		map[string]groupVersionEntry{groupVersion: groupVersions[groupVersion]},
+	))
+	return nil
+}
+
+func newGroupVersionEntry(group *metav1.APIResourceList) groupVersionEntry {
+	gv, err := schema.ParseGroupVersion(group.GroupVersion)
+	if err != nil {
+		// This shouldn't happen because we get these values from the server.
+		panic(fmt.Errorf("received invalid GroupVersion from server: %v", err))
+	}
+	gve := groupVersionEntry{
+		resources: make(map[string]*APIResource, len(group.APIResources)),
+		kinds:     make(map[string]*APIResource, len(group.APIResources)),
+	}
+	for i := range group.APIResources {
+		apiResource := &APIResource{
+			APIResource: group.APIResources[i],
+			APIVersion:  group.GroupVersion,
+		}
+		// Materialize default values from the list into each entry.
+		if apiResource.Group == "" {
+			apiResource.Group = gv.Group
+		}
+		if apiResource.Version == "" {
+			apiResource.Version = gv.Version
+		}
+		gve.resources[apiResource.Name] = apiResource
+		// Remember how to map back from Kind to resource.
+		// This is different from what RESTMapper provides because we already know
+		// the full GroupVersionKind and just need the resource name.
+		// Make sure we don't choose a subresource like "pods/status".
+		if !strings.ContainsRune(apiResource.Name, '/') {
+			gve.kinds[apiResource.Kind] = apiResource
+		}
+	}
+	return gve
+}
+
+// diff returns the resources added or removed between the old and the new group versions.
+func diff(old, cur map[string]groupVersionEntry) ResourceChange {
+	var change ResourceChange
+	for gv, gve := range cur {
+		for name, r := range gve.resources {
+			if _, ok := old[gv].resources[name]; !ok && !strings.ContainsRune(name, '/') {
+				change.Added = append(change.Added, r.GroupVersion().WithResource(name))
+			}
+		}
+	}
+	for gv, gve := range old {
+		for name, r := range gve.resources {
+			if _, ok := cur[gv].resources[name]; !ok && !strings.ContainsRune(name, '/') {
+				change.Removed = append(change.Removed, r.GroupVersion().WithResource(name))
+			}
+		}
+	}
+	return change
 }
 
 func (rm *ResourceMap) Start(refreshInterval time.Duration) {
@@ -159,6 +319,10 @@ func (rm *ResourceMap) Start(refreshInterval time.Duration) {
 
 func (rm *ResourceMap) Stop() {
 	close(rm.stopCh)
+	if rm.queue != nil {
+		rm.queue.ShutDown()
+		<-rm.workerDoneCh
+	}
 	<-rm.doneCh
 }
 
@@ -0,0 +1,259 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var fooGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "foos"}
+
+func newFakeDiscovery() *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Kind: "Pod", Namespaced: true},
+						{Name: "pods/status", Kind: "Pod", Namespaced: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+type changeRecorder struct {
+	mu      sync.Mutex
+	changes []ResourceChange
+}
+
+func (r *changeRecorder) record(change ResourceChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func (r *changeRecorder) get() []ResourceChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ResourceChange(nil), r.changes...)
+}
+
+func TestResourceMap_RefreshGroupVersion(t *testing.T) {
+	disc := newFakeDiscovery()
+	rm := NewResourceMap(disc)
+	var rec changeRecorder
+	unsubscribe := rm.Subscribe(rec.record)
+
+	rm.refresh()
+	if rm.Get("v1", "pods") == nil || rm.GetKind("v1", "Pod").Name != "pods" {
+		t.Fatal("expected pods to be discovered")
+	}
+	if changes := rec.get(); len(changes) != 1 || len(changes[0].Added) != 1 {
+		t.Fatalf("expected pods to be added, found %+v", changes)
+	}
+
+	disc.Resources = append(disc.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "foos", Kind: "Foo", Namespaced: true}},
+	})
+	if err := rm.refreshGroupVersion("example.com/v1"); err != nil {
+		t.Fatal(err)
+	}
+	if rm.Get("example.com/v1", "foos") == nil || rm.Get("v1", "pods") == nil {
+		t.Fatal("expected foos to be discovered without losing pods")
+	}
+	if changes := rec.get(); len(changes) != 2 || len(changes[1].Added) != 1 || changes[1].Added[0] != fooGVR {
+		t.Fatalf("expected foos to be added, found %+v", changes)
+	}
+
+	disc.Resources = disc.Resources[:1]
+	if err := rm.refreshGroupVersion("example.com/v1"); err != nil {
+		t.Fatal(err)
+	}
+	if rm.Get("example.com/v1", "foos") != nil {
+		t.Fatal("expected foos to be removed")
+	}
+	if changes := rec.get(); len(changes) != 3 || len(changes[2].Removed) != 1 || changes[2].Removed[0] != fooGVR {
+		t.Fatalf("expected foos to be removed, found %+v", changes)
+	}
+
+	unsubscribe()
+	rm.refresh()
+	disc.Resources = nil
+	rm.refresh()
+	if changes := rec.get(); len(changes) != 3 {
+		t.Fatalf("expected no changes after unsubscribe, found %+v", changes)
+	}
+}
+
+func TestResourceMap_StartWatching(t *testing.T) {
+	disc := newFakeDiscovery()
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			crdGVR:        "CustomResourceDefinitionList",
+			apiServiceGVR: "APIServiceList",
+		},
+	)
+
+	rm := NewResourceMap(disc)
+	rm.StartWatching(dc, time.Hour)
+	defer rm.Stop()
+	if err := wait.PollUntilContextTimeout(context.TODO(), 10*time.Millisecond, 10*time.Second, true, func(_ context.Context) (bool, error) {
+		return rm.HasSynced(), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	disc.Lock()
+	disc.Resources = append(disc.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "foos", Kind: "Foo", Namespaced: true}},
+	})
+	disc.Unlock()
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "foos.example.com"},
+		"spec": map[string]interface{}{
+			"group":    "example.com",
+			"versions": []interface{}{map[string]interface{}{"name": "v1"}},
+		},
+	}}
+	if _, err := dc.Resource(crdGVR).Create(context.TODO(), crd, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wait.PollUntilContextTimeout(context.TODO(), 10*time.Millisecond, 10*time.Second, true, func(_ context.Context) (bool, error) {
+		return rm.Get("example.com/v1", "foos") != nil, nil
+	}); err != nil {
+		t.Fatal("expected foos to be discovered after the CRD was created")
+	}
+}
+
+func newCRD(versions ...string) *unstructured.Unstructured {
+	items := make([]interface{}, 0, len(versions))
+	for _, v := range versions {
+		items = append(items, map[string]interface{}{"name": v, "served": true})
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "foos.example.com"},
+		"spec": map[string]interface{}{
+			"group":    "example.com",
+			"versions": items,
+		},
+	}}
+}
+
+func TestResourceMap_EventHandler(t *testing.T) {
+	rm := NewResourceMap(newFakeDiscovery())
+	rm.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer rm.queue.ShutDown()
+	h := rm.eventHandler(watchedResource{groupVersions: crdGroupVersions, discoveryFields: crdDiscoveryFields})
+
+	h.OnAdd(newCRD("v1"), true)
+	if n := rm.queue.Len(); n != 0 {
+		t.Errorf("expected objects of the initial list to be ignored, found %d queued", n)
+	}
+
+	old := newCRD("v1")
+	updated := old.DeepCopy()
+	_ = unstructured.SetNestedStringSlice(updated.Object, []string{"v1"}, "status", "storedVersions")
+	h.OnUpdate(old, updated)
+	if n := rm.queue.Len(); n != 0 {
+		t.Errorf("expected status updates to be ignored, found %d queued", n)
+	}
+
+	h.OnUpdate(old, newCRD("v2"))
+	if n := rm.queue.Len(); n != 2 {
+		t.Errorf("expected removed and added versions to be queued, found %d queued", n)
+	}
+}
+
+func TestResourceMap_EventHandlerEstablished(t *testing.T) {
+	rm := NewResourceMap(newFakeDiscovery())
+	rm.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer rm.queue.ShutDown()
+	h := rm.eventHandler(watchedResource{groupVersions: crdGroupVersions, discoveryFields: crdDiscoveryFields})
+
+	// the CRD is added before it is established, so the refresh finds nothing
+	added := newCRD("v1")
+	h.OnAdd(added, false)
+	item, _ := rm.queue.Get()
+	rm.queue.Done(item)
+	rm.queue.Forget(item)
+
+	accepted := added.DeepCopy()
+	_ = unstructured.SetNestedField(accepted.Object, map[string]interface{}{"kind": "Foo", "plural": "foos"}, "status", "acceptedNames")
+	h.OnUpdate(added, accepted)
+	if n := rm.queue.Len(); n != 1 {
+		t.Errorf("expected accepted names to be queued, found %d queued", n)
+	}
+	item, _ = rm.queue.Get()
+	rm.queue.Done(item)
+	rm.queue.Forget(item)
+
+	established := accepted.DeepCopy()
+	_ = unstructured.SetNestedSlice(established.Object, []interface{}{
+		map[string]interface{}{"type": "Established", "status": "True"},
+	}, "status", "conditions")
+	h.OnUpdate(accepted, established)
+	if n := rm.queue.Len(); n != 1 {
+		t.Errorf("expected established CRD to be queued, found %d queued", n)
+	}
+}
+
+func TestResourceMap_UnsubscribeFromCallback(t *testing.T) {
+	rm := NewResourceMap(newFakeDiscovery())
+	var calls int
+	var unsubscribe func()
+	unsubscribe = rm.Subscribe(func(change ResourceChange) {
+		calls++
+		unsubscribe()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rm.refresh()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock when unsubscribing from a callback")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, found %d", calls)
+	}
+}
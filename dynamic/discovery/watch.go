@@ -0,0 +1,185 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+var (
+	crdGVR = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	}
+	apiServiceGVR = schema.GroupVersionResource{
+		Group:    "apiregistration.k8s.io",
+		Version:  "v1",
+		Resource: "apiservices",
+	}
+)
+
+// StartWatching is like Start, but it also watches CustomResourceDefinitions and APIServices
+// and refreshes only the affected group versions as soon as they change. Since the watches
+// keep the map up to date, refreshInterval can be much longer than the one used with Start.
+func (rm *ResourceMap) StartWatching(dc dynamic.Interface, refreshInterval time.Duration) {
+	rm.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	rm.workerDoneCh = make(chan struct{})
+	rm.Start(refreshInterval)
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dc, 0)
+	for gvr, w := range map[schema.GroupVersionResource]watchedResource{
+		crdGVR:        {groupVersions: crdGroupVersions, discoveryFields: crdDiscoveryFields},
+		apiServiceGVR: {groupVersions: apiServiceGroupVersions, discoveryFields: apiServiceDiscoveryFields},
+	} {
+		_, _ = factory.ForResource(gvr).Informer().AddEventHandler(rm.eventHandler(w))
+	}
+	factory.Start(rm.stopCh)
+
+	go func() {
+		defer close(rm.workerDoneCh)
+		for rm.processNextGroupVersion() {
+		}
+	}()
+}
+
+type watchedResource struct {
+	// groupVersions returns the group versions served because of the object.
+	groupVersions func(*unstructured.Unstructured) []string
+	// discoveryFields returns the fields of the object that affect discovery. Updates
+	// that do not change these fields, eg, status only updates, are ignored.
+	discoveryFields func(*unstructured.Unstructured) []interface{}
+}
+
+func (rm *ResourceMap) eventHandler(w watchedResource) cache.ResourceEventHandler {
+	toUnstructured := func(obj interface{}) *unstructured.Unstructured {
+		if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = d.Obj
+		}
+		u, _ := obj.(*unstructured.Unstructured)
+		return u
+	}
+	enqueue := func(u *unstructured.Unstructured) {
+		for _, gv := range w.groupVersions(u) {
+			rm.queue.Add(gv)
+		}
+	}
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// objects of the initial list are covered by the full refresh done by Start
+			if u := toUnstructured(obj); u != nil && !isInInitialList {
+				enqueue(u)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, n := toUnstructured(oldObj), toUnstructured(newObj)
+			if o == nil || n == nil || equality.Semantic.DeepEqual(w.discoveryFields(o), w.discoveryFields(n)) {
+				return
+			}
+			// versions removed from the old object need to be refreshed too
+			enqueue(o)
+			enqueue(n)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if u := toUnstructured(obj); u != nil {
+				enqueue(u)
+			}
+		},
+	}
+}
+
+func (rm *ResourceMap) processNextGroupVersion() bool {
+	item, shutdown := rm.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer rm.queue.Done(item)
+
+	if err := rm.refreshGroupVersion(item.(string)); err != nil {
+		klog.Errorf("Failed to fetch discovery info for %s: %v", item, err)
+		rm.queue.AddRateLimited(item)
+		return true
+	}
+	rm.queue.Forget(item)
+	return true
+}
+
+// crdGroupVersions returns the group versions served by a CustomResourceDefinition.
+func crdGroupVersions(crd *unstructured.Unstructured) []string {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	out := make([]string, 0, len(versions))
+	for _, v := range versions {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := m["name"].(string); ok {
+			out = append(out, schema.GroupVersion{Group: group, Version: name}.String())
+		}
+	}
+	return out
+}
+
+// crdDiscoveryFields returns the group, versions, accepted names and whether a
+// CustomResourceDefinition is established, since its resources are only discovered
+// once the names are accepted and it is established.
+func crdDiscoveryFields(crd *unstructured.Unstructured) []interface{} {
+	group, _, _ := unstructured.NestedFieldNoCopy(crd.Object, "spec", "group")
+	versions, _, _ := unstructured.NestedFieldNoCopy(crd.Object, "spec", "versions")
+	acceptedNames, _, _ := unstructured.NestedFieldNoCopy(crd.Object, "status", "acceptedNames")
+	return []interface{}{group, versions, acceptedNames, conditionStatus(crd, "Established")}
+}
+
+// apiServiceGroupVersions returns the group version registered by an APIService.
+func apiServiceGroupVersions(svc *unstructured.Unstructured) []string {
+	group, _, _ := unstructured.NestedString(svc.Object, "spec", "group")
+	version, _, _ := unstructured.NestedString(svc.Object, "spec", "version")
+	if version == "" {
+		return nil
+	}
+	return []string{schema.GroupVersion{Group: group, Version: version}.String()}
+}
+
+// apiServiceDiscoveryFields returns the group version of an APIService and whether it is
+// available, since the resources of an aggregated api are only discovered while it is available.
+func apiServiceDiscoveryFields(svc *unstructured.Unstructured) []interface{} {
+	group, _, _ := unstructured.NestedString(svc.Object, "spec", "group")
+	version, _, _ := unstructured.NestedString(svc.Object, "spec", "version")
+	return []interface{}{group, version, conditionStatus(svc, "Available")}
+}
+
+// conditionStatus returns the status of the condition with the given type in status.conditions.
+func conditionStatus(u *unstructured.Unstructured, conditionType string) string {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		if m, ok := c.(map[string]interface{}); ok && m["type"] == conditionType {
+			status, _ := m["status"].(string)
+			return status
+		}
+	}
+	return ""
+}
@@ -17,52 +17,250 @@ limitations under the License.
 package factory
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
 )
 
 type cachedImpl struct {
+	// dc, defaultResync, namespace and tweakListOptions are used to create a separate
+	// informer per resource, so that it can be stopped when no longer needed.
+	dc               dynamic.Interface
+	defaultResync    time.Duration
+	namespace        string
+	tweakListOptions dynamicinformer.TweakListOptionsFunc
+
+	// factory is set when the informers are shared with other users. Those informers are
+	// never stopped by the cachedImpl.
 	factory dynamicinformer.DynamicSharedInformerFactory
 	stopCh  <-chan struct{}
 
-	lock    sync.RWMutex
-	listers map[schema.GroupVersionResource]dynamiclister.Lister
+	lock      sync.RWMutex
+	informers map[schema.GroupVersionResource]*informerEntry
+}
+
+type informerEntry struct {
+	informer cache.SharedIndexInformer
+	lister   dynamiclister.Lister
+	refs     int
+	started  time.Time
+
+	// ctx is cancelled when the informer is released or evicted.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-var _ Factory = &cachedImpl{}
+var _ CachedFactory = &cachedImpl{}
 
 func (i *cachedImpl) ForResource(gvr schema.GroupVersionResource) dynamiclister.Lister {
-	l := i.existingForResource(gvr)
-	if l != nil {
-		return l
+	return i.forResource(gvr, false)
+}
+
+func (i *cachedImpl) Acquire(gvr schema.GroupVersionResource) dynamiclister.Lister {
+	return i.forResource(gvr, true)
+}
+
+func (i *cachedImpl) forResource(gvr schema.GroupVersionResource, acquire bool) dynamiclister.Lister {
+	e := i.existingForResource(gvr, acquire)
+	if e == nil {
+		e = i.newForResource(gvr, acquire)
 	}
-	return i.newForResource(gvr)
+	if !e.informer.HasSynced() && !cache.WaitForCacheSync(e.ctx.Done(), e.informer.HasSynced) {
+		// the informer was released, evicted or the factory was stopped while waiting
+		return &errLister{err: fmt.Errorf("informer for %s was stopped before it synced", gvr)}
+	}
+	return e.lister
 }
 
-func (i *cachedImpl) newForResource(gvr schema.GroupVersionResource) dynamiclister.Lister {
+func (i *cachedImpl) newForResource(gvr schema.GroupVersionResource, acquire bool) *informerEntry {
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	informerDep := i.factory.ForResource(gvr)
-	i.factory.Start(i.stopCh)
-	if synced := i.factory.WaitForCacheSync(i.stopCh); !synced[gvr] {
-		panic(fmt.Sprintf("informer for %s hasn't synced", gvr))
+	// another caller might have started the informer while the lock was released
+	if e, ok := i.informers[gvr]; ok {
+		if acquire {
+			e.refs++
+		}
+		return e
 	}
-	l := dynamiclister.New(informerDep.Informer().GetIndexer(), gvr)
-	i.listers[gvr] = l
-	return l
+
+	e := &informerEntry{
+		started: time.Now(),
+	}
+	e.ctx, e.cancel = context.WithCancel(wait.ContextForChannel(i.stopCh))
+	if acquire {
+		e.refs = 1
+	}
+	if i.factory != nil {
+		e.informer = i.factory.ForResource(gvr).Informer()
+	} else {
+		e.informer = dynamicinformer.NewFilteredDynamicInformer(i.dc, gvr, i.namespace, i.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, i.tweakListOptions).Informer()
+	}
+	// fails if a shared informer was already started by someone else
+	_ = e.informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(r, err)
+		if kerr.IsNotFound(err) || meta.IsNoMatchError(err) {
+			klog.V(3).Infof("evicting informer for %s, resource no longer exists", gvr)
+			i.evict(gvr, e)
+		}
+	})
+	e.lister = dynamiclister.New(e.informer.GetIndexer(), gvr)
+	i.informers[gvr] = e
+
+	if i.factory != nil {
+		i.factory.Start(i.stopCh)
+	} else {
+		go e.informer.Run(e.ctx.Done())
+	}
+	return e
+}
+
+func (i *cachedImpl) existingForResource(gvr schema.GroupVersionResource, acquire bool) *informerEntry {
+	if acquire {
+		i.lock.Lock()
+		defer i.lock.Unlock()
+	} else {
+		i.lock.RLock()
+		defer i.lock.RUnlock()
+	}
+	e, ok := i.informers[gvr]
+	if !ok {
+		return nil
+	}
+	if acquire {
+		e.refs++
+	}
+	return e
+}
+
+func (i *cachedImpl) Release(gvr schema.GroupVersionResource) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	e, ok := i.informers[gvr]
+	if !ok {
+		return
+	}
+	if e.refs > 0 {
+		e.refs--
+	}
+	if e.refs == 0 {
+		delete(i.informers, gvr)
+		e.cancel()
+	}
+}
+
+// evict stops the informer e, unless it has already been replaced by a new informer.
+func (i *cachedImpl) evict(gvr schema.GroupVersionResource, e *informerEntry) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if cur, ok := i.informers[gvr]; ok && cur == e {
+		delete(i.informers, gvr)
+	}
+	e.cancel()
 }
 
-func (i *cachedImpl) existingForResource(gvr schema.GroupVersionResource) dynamiclister.Lister {
+func (i *cachedImpl) HasSynced(gvr schema.GroupVersionResource) bool {
 	i.lock.RLock()
 	defer i.lock.RUnlock()
-	l, ok := i.listers[gvr]
+
+	e, ok := i.informers[gvr]
+	return ok && e.informer.HasSynced()
+}
+
+func (i *cachedImpl) WaitForCacheSync(stopCh <-chan struct{}, gvr schema.GroupVersionResource) bool {
+	i.lock.RLock()
+	e, ok := i.informers[gvr]
+	i.lock.RUnlock()
 	if !ok {
-		return nil
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return cache.WaitForCacheSync(ctx.Done(), e.informer.HasSynced)
+}
+
+func (i *cachedImpl) Stats() []InformerStats {
+	type entry struct {
+		InformerStats
+		informer cache.SharedIndexInformer
 	}
+
+	i.lock.RLock()
+	entries := make([]entry, 0, len(i.informers))
+	for gvr, e := range i.informers {
+		entries = append(entries, entry{
+			InformerStats: InformerStats{
+				Resource:  gvr,
+				Refs:      e.refs,
+				StartedAt: e.started,
+			},
+			informer: e.informer,
+		})
+	}
+	i.lock.RUnlock()
+
+	// the size of the caches is measured without holding the lock
+	stats := make([]InformerStats, 0, len(entries))
+	for _, e := range entries {
+		s := e.InformerStats
+		s.Synced = e.informer.HasSynced()
+		for _, obj := range e.informer.GetStore().List() {
+			s.Objects++
+			if data, err := json.Marshal(obj); err == nil {
+				s.Bytes += int64(len(data))
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(x, y int) bool {
+		return stats[x].Resource.String() < stats[y].Resource.String()
+	})
+	return stats
+}
+
+// errLister is returned when an informer stops before its cache is synced.
+type errLister struct {
+	err error
+}
+
+var (
+	_ dynamiclister.Lister          = &errLister{}
+	_ dynamiclister.NamespaceLister = &errLister{}
+)
+
+func (l *errLister) List(labels.Selector) ([]*unstructured.Unstructured, error) {
+	return nil, l.err
+}
+
+func (l *errLister) Get(string) (*unstructured.Unstructured, error) {
+	return nil, l.err
+}
+
+func (l *errLister) Namespace(string) dynamiclister.NamespaceLister {
 	return l
 }
@@ -0,0 +1,141 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var configMapGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func newConfigMap(name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace("default")
+	u.SetName(name)
+	return u
+}
+
+func TestCachedFactory_Lifecycle(t *testing.T) {
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMapGVR: "ConfigMapList"},
+		newConfigMap("a"),
+		newConfigMap("b"),
+	)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f := NewCached(dc, 0, stopCh)
+
+	if f.HasSynced(configMapGVR) {
+		t.Fatal("expected informer not to be running before first use")
+	}
+	if f.WaitForCacheSync(stopCh, configMapGVR) {
+		t.Fatal("expected WaitForCacheSync to fail for an informer that is not running")
+	}
+
+	objs, err := f.Acquire(configMapGVR).List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Errorf("expected 2 objects, found %d", len(objs))
+	}
+	f.Acquire(configMapGVR)
+	if !f.HasSynced(configMapGVR) || !f.WaitForCacheSync(stopCh, configMapGVR) {
+		t.Fatal("expected informer to be synced")
+	}
+
+	stats := f.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected stats for 1 informer, found %d", len(stats))
+	}
+	if s := stats[0]; s.Refs != 2 || s.Objects != 2 || s.Bytes == 0 || !s.Synced {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	f.Release(configMapGVR)
+	if !f.HasSynced(configMapGVR) {
+		t.Error("expected informer to keep running while referenced")
+	}
+	f.Release(configMapGVR)
+	if f.HasSynced(configMapGVR) || len(f.Stats()) != 0 {
+		t.Error("expected informer to be stopped after the last reference is released")
+	}
+
+	// informer is restarted on next use
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.ForResource(configMapGVR)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for informer to restart")
+	}
+	if s := f.Stats(); len(s) != 1 || s[0].Refs != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestCachedFactory_ReleaseBeforeSync(t *testing.T) {
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMapGVR: "ConfigMapList"},
+		newConfigMap("a"),
+	)
+	unblock := make(chan struct{})
+	defer close(unblock)
+	dc.PrependReactor("list", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		<-unblock
+		return false, nil, nil
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f := NewCached(dc, 0, stopCh)
+
+	result := make(chan error)
+	go func() {
+		_, err := f.ForResource(configMapGVR).List(labels.Everything())
+		result <- err
+	}()
+	if err := wait.PollUntilContextTimeout(context.TODO(), 10*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		return len(f.Stats()) == 1, nil
+	}); err != nil {
+		t.Fatal("timed out waiting for informer to start")
+	}
+	f.Release(configMapGVR)
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Error("expected error from informer released before it synced")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for ForResource to return")
+	}
+}
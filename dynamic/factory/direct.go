@@ -22,8 +22,10 @@ import (
 	"reflect"
 	"sync"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -33,7 +35,8 @@ import (
 )
 
 type directImpl struct {
-	dc dynamic.Interface
+	dc       dynamic.Interface
+	pageSize int64
 
 	lock    sync.RWMutex
 	listers map[schema.GroupVersionResource]dynamiclister.Lister
@@ -53,7 +56,7 @@ func (i *directImpl) newForResource(gvr schema.GroupVersionResource) dynamiclist
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	l := newLister(i.dc, gvr)
+	l := newLister(i.dc, gvr, i.pageSize)
 	i.listers[gvr] = l
 	return l
 }
@@ -68,39 +71,63 @@ func (i *directImpl) existingForResource(gvr schema.GroupVersionResource) dynami
 	return l
 }
 
+// ListOptions are the options of a list call of the direct listers.
+type ListOptions struct {
+	// LabelSelector selects objects by labels. Defaults to everything.
+	LabelSelector labels.Selector
+	// FieldSelector selects objects by fields. Defaults to everything.
+	FieldSelector fields.Selector
+	// PageSize is the maximum number of objects fetched per api call. If zero, the page size of
+	// the factory is used. If negative, all objects are fetched in a single call.
+	PageSize int64
+	// ResourceVersion and ResourceVersionMatch control the consistency of the list call.
+	// See https://kubernetes.io/docs/reference/using-api/api-concepts/#semantics-for-get-and-list
+	// The resource version is only sent with the first page, following pages are served
+	// from the same snapshot using the continue token.
+	ResourceVersion      string
+	ResourceVersionMatch metav1.ResourceVersionMatch
+}
+
+// Pager is implemented by the listers and the namespace listers returned by the direct factory.
+// It lists objects page by page, so that large lists can be processed without loading all the
+// objects in memory.
+type Pager interface {
+	// ListWithOptions returns all the objects matching the options.
+	ListWithOptions(ctx context.Context, opts ListOptions) ([]*unstructured.Unstructured, error)
+	// EachListItem calls fn for each object matching the options. Iteration stops at the first error.
+	EachListItem(ctx context.Context, opts ListOptions, fn func(obj *unstructured.Unstructured) error) error
+}
+
 var (
 	_ dynamiclister.Lister          = &dynamicLister{}
 	_ dynamiclister.NamespaceLister = &dynamicNamespaceLister{}
+	_ Pager                         = &dynamicLister{}
+	_ Pager                         = &dynamicNamespaceLister{}
 )
 
 // dynamicLister implements the Lister interface.
 type dynamicLister struct {
-	dc  dynamic.Interface
-	gvr schema.GroupVersionResource
+	dc       dynamic.Interface
+	gvr      schema.GroupVersionResource
+	pageSize int64
 }
 
 // newLister returns a new Lister.
-func newLister(dc dynamic.Interface, gvr schema.GroupVersionResource) dynamiclister.Lister {
-	return &dynamicLister{dc: dc, gvr: gvr}
+func newLister(dc dynamic.Interface, gvr schema.GroupVersionResource, pageSize int64) dynamiclister.Lister {
+	return &dynamicLister{dc: dc, gvr: gvr, pageSize: pageSize}
 }
 
 // List lists all resources in the indexer.
 func (l *dynamicLister) List(selector labels.Selector) (ret []*unstructured.Unstructured, err error) {
-	fn := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
-		return l.dc.Resource(l.gvr).List(ctx, opts)
-	}
-	opts := metav1.ListOptions{
-		LabelSelector: selector.String(),
-	}
-	err = pager.New(fn).EachListItem(context.TODO(), opts, func(obj runtime.Object) error {
-		o, ok := obj.(*unstructured.Unstructured)
-		if !ok {
-			return fmt.Errorf("expected *unstructured.Unstructured, found %s", reflect.TypeOf(obj))
-		}
-		ret = append(ret, o)
-		return nil
-	})
-	return ret, err
+	return l.ListWithOptions(context.TODO(), ListOptions{LabelSelector: selector})
+}
+
+func (l *dynamicLister) ListWithOptions(ctx context.Context, opts ListOptions) ([]*unstructured.Unstructured, error) {
+	return collect(ctx, l, opts)
+}
+
+func (l *dynamicLister) EachListItem(ctx context.Context, opts ListOptions, fn func(obj *unstructured.Unstructured) error) error {
+	return eachListItem(ctx, l.dc.Resource(l.gvr), l.pageSize, opts, fn)
 }
 
 // Get retrieves a resource from the indexer with the given name
@@ -114,7 +141,7 @@ func (l *dynamicLister) Get(name string) (*unstructured.Unstructured, error) {
 
 // Namespace returns an object that can list and get resources from a given namespace.
 func (l *dynamicLister) Namespace(namespace string) dynamiclister.NamespaceLister {
-	return &dynamicNamespaceLister{dc: l.dc, namespace: namespace, gvr: l.gvr}
+	return &dynamicNamespaceLister{dc: l.dc, namespace: namespace, gvr: l.gvr, pageSize: l.pageSize}
 }
 
 // dynamicNamespaceLister implements the NamespaceLister interface.
@@ -122,32 +149,90 @@ type dynamicNamespaceLister struct {
 	dc        dynamic.Interface
 	namespace string
 	gvr       schema.GroupVersionResource
+	pageSize  int64
 }
 
 // List lists all resources in the indexer for a given namespace.
 func (l *dynamicNamespaceLister) List(selector labels.Selector) (ret []*unstructured.Unstructured, err error) {
-	fn := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
-		return l.dc.Resource(l.gvr).Namespace(l.namespace).List(ctx, opts)
+	return l.ListWithOptions(context.TODO(), ListOptions{LabelSelector: selector})
+}
+
+func (l *dynamicNamespaceLister) ListWithOptions(ctx context.Context, opts ListOptions) ([]*unstructured.Unstructured, error) {
+	return collect(ctx, l, opts)
+}
+
+func (l *dynamicNamespaceLister) EachListItem(ctx context.Context, opts ListOptions, fn func(obj *unstructured.Unstructured) error) error {
+	return eachListItem(ctx, l.dc.Resource(l.gvr).Namespace(l.namespace), l.pageSize, opts, fn)
+}
+
+// Get retrieves a resource from the indexer for a given namespace and name.
+func (l *dynamicNamespaceLister) Get(name string) (*unstructured.Unstructured, error) {
+	obj, err := l.dc.Resource(l.gvr).Namespace(l.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func collect(ctx context.Context, p Pager, opts ListOptions) (ret []*unstructured.Unstructured, err error) {
+	err = p.EachListItem(ctx, opts, func(obj *unstructured.Unstructured) error {
+		ret = append(ret, obj)
+		return nil
+	})
+	return ret, err
+}
+
+func eachListItem(ctx context.Context, ri dynamic.ResourceInterface, pageSize int64, opts ListOptions, fn func(obj *unstructured.Unstructured) error) error {
+	listOpts, err := opts.toListOptions()
+	if err != nil {
+		return err
+	}
+
+	p := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return ri.List(ctx, opts)
+	})
+	if opts.PageSize != 0 {
+		pageSize = opts.PageSize
 	}
-	opts := metav1.ListOptions{
-		LabelSelector: selector.String(),
+	if pageSize < 0 {
+		p.PageSize = 0
+	} else if pageSize > 0 {
+		p.PageSize = pageSize
 	}
-	err = pager.New(fn).EachListItem(context.TODO(), opts, func(obj runtime.Object) error {
+	// a continue token can't be used to resume a list at an exact resource version
+	p.FullListIfExpired = opts.ResourceVersionMatch != metav1.ResourceVersionMatchExact
+
+	return p.EachListItem(ctx, listOpts, func(obj runtime.Object) error {
 		o, ok := obj.(*unstructured.Unstructured)
 		if !ok {
 			return fmt.Errorf("expected *unstructured.Unstructured, found %s", reflect.TypeOf(obj))
 		}
-		ret = append(ret, o)
-		return nil
+		return fn(o)
 	})
-	return ret, err
 }
 
-// Get retrieves a resource from the indexer for a given namespace and name.
-func (l *dynamicNamespaceLister) Get(name string) (*unstructured.Unstructured, error) {
-	obj, err := l.dc.Resource(l.gvr).Namespace(l.namespace).Get(context.TODO(), name, metav1.GetOptions{})
-	if err != nil {
-		return nil, err
+func (opts ListOptions) toListOptions() (metav1.ListOptions, error) {
+	var out metav1.ListOptions
+	if opts.LabelSelector != nil {
+		out.LabelSelector = opts.LabelSelector.String()
 	}
-	return obj, nil
+	if opts.FieldSelector != nil {
+		out.FieldSelector = opts.FieldSelector.String()
+	}
+	switch opts.ResourceVersionMatch {
+	case "":
+	case metav1.ResourceVersionMatchExact:
+		if opts.ResourceVersion == "" || opts.ResourceVersion == "0" {
+			return out, errors.NewBadRequest(fmt.Sprintf("resourceVersionMatch %s requires a non-zero resourceVersion", opts.ResourceVersionMatch))
+		}
+	case metav1.ResourceVersionMatchNotOlderThan:
+		if opts.ResourceVersion == "" {
+			return out, errors.NewBadRequest(fmt.Sprintf("resourceVersionMatch %s requires a resourceVersion", opts.ResourceVersionMatch))
+		}
+	default:
+		return out, errors.NewBadRequest(fmt.Sprintf("unknown resourceVersionMatch %s", opts.ResourceVersionMatch))
+	}
+	out.ResourceVersion = opts.ResourceVersion
+	out.ResourceVersionMatch = opts.ResourceVersionMatch
+	return out, nil
 }
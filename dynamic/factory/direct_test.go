@@ -0,0 +1,73 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPager(t *testing.T) {
+	a := newConfigMap("a")
+	a.SetLabels(map[string]string{"app": "demo"})
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMapGVR: "ConfigMapList"},
+		a,
+		newConfigMap("b"),
+		newConfigMap("c"),
+	)
+	p := NewWithPageSize(dc, 1).ForResource(configMapGVR).Namespace("default").(Pager)
+
+	objs, err := p.ListWithOptions(context.TODO(), ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"app": "demo"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].GetName() != "a" {
+		t.Errorf("unexpected objects %v", objs)
+	}
+
+	errStop := errors.New("stop")
+	var seen int
+	err = p.EachListItem(context.TODO(), ListOptions{}, func(obj *unstructured.Unstructured) error {
+		seen++
+		if seen == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || seen != 2 {
+		t.Errorf("expected iteration to stop after 2 objects, seen %d, err %v", seen, err)
+	}
+
+	_, err = p.ListWithOptions(context.TODO(), ListOptions{ResourceVersionMatch: metav1.ResourceVersionMatchExact, ResourceVersion: "0"})
+	if !kerr.IsBadRequest(err) {
+		t.Errorf("expected bad request error, found %v", err)
+	}
+	_, err = p.ListWithOptions(context.TODO(), ListOptions{ResourceVersionMatch: metav1.ResourceVersionMatchNotOlderThan, ResourceVersion: "0"})
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -19,10 +19,14 @@ package factory
 import (
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/metadata/metadatalister"
 )
 
 type Factory interface {
@@ -30,32 +34,117 @@ type Factory interface {
 }
 
 func New(dc dynamic.Interface) Factory {
+	return NewWithPageSize(dc, 0)
+}
+
+// NewWithPageSize returns a Factory that reads directly from the api server and lists objects
+// in pages of pageSize objects. If pageSize is zero, the client-go default page size is used.
+// If pageSize is negative, lists are not paginated. The listers implement Pager.
+func NewWithPageSize(dc dynamic.Interface, pageSize int64) Factory {
 	return &directImpl{
-		dc:      dc,
-		listers: map[schema.GroupVersionResource]dynamiclister.Lister{},
+		dc:       dc,
+		pageSize: pageSize,
+		listers:  map[schema.GroupVersionResource]dynamiclister.Lister{},
 	}
 }
 
-func NewCached(dc dynamic.Interface, defaultResync time.Duration, stopCh <-chan struct{}) Factory {
+// CachedFactory is a Factory backed by informers. Informers are started lazily by ForResource
+// or Acquire. Each Acquire takes a reference to the informer that is dropped by Release. When
+// the last reference is released, the informer is stopped. Informers that were only started via
+// ForResource are stopped by a single call to Release. Informers are also evicted when their
+// resource is removed from the api server, eg, when the CRD is deleted.
+type CachedFactory interface {
+	Factory
+	// Acquire returns the lister for gvr and takes a reference to its informer.
+	Acquire(gvr schema.GroupVersionResource) dynamiclister.Lister
+	// Release drops a reference to the informer for gvr.
+	Release(gvr schema.GroupVersionResource)
+	// HasSynced returns true if the informer for gvr is running and has synced.
+	HasSynced(gvr schema.GroupVersionResource) bool
+	// WaitForCacheSync waits until the informer for gvr has synced. It returns false if the
+	// informer is not running or is stopped before it syncs.
+	WaitForCacheSync(stopCh <-chan struct{}, gvr schema.GroupVersionResource) bool
+	// Stats returns the stats of the running informers.
+	Stats() []InformerStats
+}
+
+// InformerStats describes the size of the cache of an informer.
+type InformerStats struct {
+	Resource  schema.GroupVersionResource
+	Refs      int
+	Synced    bool
+	StartedAt time.Time
+	Objects   int
+	// Bytes is an estimate of the memory used by the cached objects, measured as the size
+	// of their json encoding.
+	Bytes int64
+}
+
+func NewCached(dc dynamic.Interface, defaultResync time.Duration, stopCh <-chan struct{}) CachedFactory {
+	return NewFilteredCached(dc, defaultResync, metav1.NamespaceAll, nil, stopCh)
+}
+
+func NewFilteredCached(dc dynamic.Interface, defaultResync time.Duration, namespace string, tweakListOptions dynamicinformer.TweakListOptionsFunc, stopCh <-chan struct{}) CachedFactory {
 	return &cachedImpl{
-		factory: dynamicinformer.NewDynamicSharedInformerFactory(dc, defaultResync),
-		stopCh:  stopCh,
-		listers: map[schema.GroupVersionResource]dynamiclister.Lister{},
+		dc:               dc,
+		defaultResync:    defaultResync,
+		namespace:        namespace,
+		tweakListOptions: tweakListOptions,
+		stopCh:           stopCh,
+		informers:        map[schema.GroupVersionResource]*informerEntry{},
 	}
 }
 
-func NewFilteredCached(dc dynamic.Interface, defaultResync time.Duration, namespace string, tweakListOptions dynamicinformer.TweakListOptionsFunc, stopCh <-chan struct{}) Factory {
+// NewSharedCached returns a CachedFactory that uses the informers of the given factory.
+// Since those informers may be used by others, Release and eviction only forget the
+// lister; the informers keep running until stopCh is closed.
+func NewSharedCached(factory dynamicinformer.DynamicSharedInformerFactory, stopCh <-chan struct{}) CachedFactory {
 	return &cachedImpl{
-		factory: dynamicinformer.NewFilteredDynamicSharedInformerFactory(dc, defaultResync, namespace, tweakListOptions),
+		factory:   factory,
+		stopCh:    stopCh,
+		informers: map[schema.GroupVersionResource]*informerEntry{},
+	}
+}
+
+// MetadataFactory is a Factory that returns listers of object metadata only. It is suitable for
+// controllers that only need names, labels and owner references of a large number of objects.
+type MetadataFactory interface {
+	ForResource(gvr schema.GroupVersionResource) metadatalister.Lister
+}
+
+// NewMetadata returns a MetadataFactory that reads object metadata directly from the api server.
+func NewMetadata(mc metadata.Interface) MetadataFactory {
+	return &metadataDirectImpl{
+		mc:      mc,
+		listers: map[schema.GroupVersionResource]metadatalister.Lister{},
+	}
+}
+
+// NewMetadataCached returns a MetadataFactory backed by metadata informers. Informers are started
+// lazily by ForResource and run until stopCh is closed.
+func NewMetadataCached(mc metadata.Interface, defaultResync time.Duration, stopCh <-chan struct{}) MetadataFactory {
+	return &metadataCachedImpl{
+		factory: metadatainformer.NewSharedInformerFactory(mc, defaultResync),
 		stopCh:  stopCh,
-		listers: map[schema.GroupVersionResource]dynamiclister.Lister{},
+		listers: map[schema.GroupVersionResource]metadatalister.Lister{},
 	}
 }
 
-func NewSharedCached(factory dynamicinformer.DynamicSharedInformerFactory, stopCh <-chan struct{}) Factory {
-	return &cachedImpl{
+// NewFilteredMetadataCached is like NewMetadataCached, but the informers only watch the given
+// namespace and use tweakListOptions to filter the listed objects.
+func NewFilteredMetadataCached(mc metadata.Interface, defaultResync time.Duration, namespace string, tweakListOptions metadatainformer.TweakListOptionsFunc, stopCh <-chan struct{}) MetadataFactory {
+	return &metadataCachedImpl{
+		factory: metadatainformer.NewFilteredSharedInformerFactory(mc, defaultResync, namespace, tweakListOptions),
+		stopCh:  stopCh,
+		listers: map[schema.GroupVersionResource]metadatalister.Lister{},
+	}
+}
+
+// NewSharedMetadataCached returns a MetadataFactory that uses the informers of the given factory.
+func NewSharedMetadataCached(factory metadatainformer.SharedInformerFactory, stopCh <-chan struct{}) MetadataFactory {
+	return &metadataCachedImpl{
 		factory: factory,
 		stopCh:  stopCh,
-		listers: map[schema.GroupVersionResource]dynamiclister.Lister{},
+		listers: map[schema.GroupVersionResource]metadatalister.Lister{},
 	}
 }
@@ -0,0 +1,98 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/metadata/metadatalister"
+)
+
+type metadataCachedImpl struct {
+	factory metadatainformer.SharedInformerFactory
+	stopCh  <-chan struct{}
+
+	lock    sync.RWMutex
+	listers map[schema.GroupVersionResource]metadatalister.Lister
+}
+
+var _ MetadataFactory = &metadataCachedImpl{}
+
+func (i *metadataCachedImpl) ForResource(gvr schema.GroupVersionResource) metadatalister.Lister {
+	l := i.existingForResource(gvr)
+	if l != nil {
+		return l
+	}
+	return i.newForResource(gvr)
+}
+
+func (i *metadataCachedImpl) newForResource(gvr schema.GroupVersionResource) metadatalister.Lister {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	// another caller might have created the lister while the lock was released
+	if l, ok := i.listers[gvr]; ok {
+		return l
+	}
+
+	informerDep := i.factory.ForResource(gvr)
+	i.factory.Start(i.stopCh)
+	if synced := i.factory.WaitForCacheSync(i.stopCh); !synced[gvr] {
+		// not cached, so that the next call waits for the informer again
+		return &errMetadataLister{err: fmt.Errorf("informer for %s was stopped before it synced", gvr)}
+	}
+	l := metadatalister.New(informerDep.Informer().GetIndexer(), gvr)
+	i.listers[gvr] = l
+	return l
+}
+
+func (i *metadataCachedImpl) existingForResource(gvr schema.GroupVersionResource) metadatalister.Lister {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	l, ok := i.listers[gvr]
+	if !ok {
+		return nil
+	}
+	return l
+}
+
+// errMetadataLister is returned when an informer stops before its cache is synced.
+type errMetadataLister struct {
+	err error
+}
+
+var (
+	_ metadatalister.Lister          = &errMetadataLister{}
+	_ metadatalister.NamespaceLister = &errMetadataLister{}
+)
+
+func (l *errMetadataLister) List(labels.Selector) ([]*metav1.PartialObjectMetadata, error) {
+	return nil, l.err
+}
+
+func (l *errMetadataLister) Get(string) (*metav1.PartialObjectMetadata, error) {
+	return nil, l.err
+}
+
+func (l *errMetadataLister) Namespace(string) metadatalister.NamespaceLister {
+	return l
+}
@@ -0,0 +1,137 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatalister"
+	"k8s.io/client-go/tools/pager"
+)
+
+type metadataDirectImpl struct {
+	mc metadata.Interface
+
+	lock    sync.RWMutex
+	listers map[schema.GroupVersionResource]metadatalister.Lister
+}
+
+var _ MetadataFactory = &metadataDirectImpl{}
+
+func (i *metadataDirectImpl) ForResource(gvr schema.GroupVersionResource) metadatalister.Lister {
+	l := i.existingForResource(gvr)
+	if l != nil {
+		return l
+	}
+	return i.newForResource(gvr)
+}
+
+func (i *metadataDirectImpl) newForResource(gvr schema.GroupVersionResource) metadatalister.Lister {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	l := newMetadataLister(i.mc, gvr)
+	i.listers[gvr] = l
+	return l
+}
+
+func (i *metadataDirectImpl) existingForResource(gvr schema.GroupVersionResource) metadatalister.Lister {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	l, ok := i.listers[gvr]
+	if !ok {
+		return nil
+	}
+	return l
+}
+
+var (
+	_ metadatalister.Lister          = &metadataLister{}
+	_ metadatalister.NamespaceLister = &metadataNamespaceLister{}
+)
+
+// metadataLister implements the metadatalister.Lister interface.
+type metadataLister struct {
+	mc  metadata.Interface
+	gvr schema.GroupVersionResource
+}
+
+// newMetadataLister returns a new metadatalister.Lister.
+func newMetadataLister(mc metadata.Interface, gvr schema.GroupVersionResource) metadatalister.Lister {
+	return &metadataLister{mc: mc, gvr: gvr}
+}
+
+// List lists all resources.
+func (l *metadataLister) List(selector labels.Selector) (ret []*metav1.PartialObjectMetadata, err error) {
+	fn := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return l.mc.Resource(l.gvr).List(ctx, opts)
+	}
+	return listMetadata(fn, selector)
+}
+
+// Get retrieves a resource with the given name.
+func (l *metadataLister) Get(name string) (*metav1.PartialObjectMetadata, error) {
+	return l.mc.Resource(l.gvr).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// Namespace returns an object that can list and get resources from a given namespace.
+func (l *metadataLister) Namespace(namespace string) metadatalister.NamespaceLister {
+	return &metadataNamespaceLister{mc: l.mc, namespace: namespace, gvr: l.gvr}
+}
+
+// metadataNamespaceLister implements the metadatalister.NamespaceLister interface.
+type metadataNamespaceLister struct {
+	mc        metadata.Interface
+	namespace string
+	gvr       schema.GroupVersionResource
+}
+
+// List lists all resources for a given namespace.
+func (l *metadataNamespaceLister) List(selector labels.Selector) (ret []*metav1.PartialObjectMetadata, err error) {
+	fn := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return l.mc.Resource(l.gvr).Namespace(l.namespace).List(ctx, opts)
+	}
+	return listMetadata(fn, selector)
+}
+
+// Get retrieves a resource for a given namespace and name.
+func (l *metadataNamespaceLister) Get(name string) (*metav1.PartialObjectMetadata, error) {
+	return l.mc.Resource(l.gvr).Namespace(l.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func listMetadata(fn pager.ListPageFunc, selector labels.Selector) (ret []*metav1.PartialObjectMetadata, err error) {
+	opts := metav1.ListOptions{
+		LabelSelector: selector.String(),
+	}
+	err = pager.New(fn).EachListItem(context.TODO(), opts, func(obj runtime.Object) error {
+		o, ok := obj.(*metav1.PartialObjectMetadata)
+		if !ok {
+			return fmt.Errorf("expected *metav1.PartialObjectMetadata, found %s", reflect.TypeOf(obj))
+		}
+		ret = append(ret, o)
+		return nil
+	})
+	return ret, err
+}
@@ -0,0 +1,99 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func newMetadataClient(t *testing.T) *metadatafake.FakeMetadataClient {
+	t.Helper()
+	scheme := metadatafake.NewTestScheme()
+	_ = metav1.AddMetaToScheme(scheme)
+	mc := metadatafake.NewSimpleMetadataClient(scheme)
+	for _, name := range []string{"a", "b"} {
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(configMapGVR.GroupVersion().WithKind("ConfigMap"))
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		if name == "a" {
+			obj.SetLabels(map[string]string{"app": "demo"})
+		}
+		if err := mc.Tracker().Create(configMapGVR, obj, obj.Namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mc
+}
+
+func testMetadataFactory(t *testing.T, f MetadataFactory) {
+	t.Helper()
+	l := f.ForResource(configMapGVR)
+	if f.ForResource(configMapGVR) != l {
+		t.Errorf("expected lister to be reused")
+	}
+
+	objs, err := l.List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Errorf("expected 2 objects, found %d", len(objs))
+	}
+
+	objs, err = l.Namespace("default").List(labels.SelectorFromSet(labels.Set{"app": "demo"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].GetName() != "a" {
+		t.Errorf("unexpected objects %v", objs)
+	}
+
+	obj, err := l.Namespace("default").Get("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetName() != "b" {
+		t.Errorf("unexpected object %v", obj)
+	}
+}
+
+func TestMetadataFactory(t *testing.T) {
+	testMetadataFactory(t, NewMetadata(newMetadataClient(t)))
+}
+
+func TestMetadataCachedFactory(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	testMetadataFactory(t, NewMetadataCached(newMetadataClient(t), 0, stopCh))
+}
+
+func TestMetadataCachedFactory_Stopped(t *testing.T) {
+	stopCh := make(chan struct{})
+	close(stopCh)
+	f := NewMetadataCached(newMetadataClient(t), 0, stopCh)
+	if _, err := f.ForResource(configMapGVR).List(labels.Everything()); err == nil {
+		t.Error("expected error from informer stopped before it synced")
+	}
+	if _, err := f.ForResource(configMapGVR).Namespace("default").Get("a"); err == nil {
+		t.Error("expected error from informer stopped before it synced")
+	}
+}
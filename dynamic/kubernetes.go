@@ -18,29 +18,22 @@ package dynamic
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	v1 "kmodules.xyz/client-go/core/v1"
 	discovery_util "kmodules.xyz/client-go/discovery"
 
-	"github.com/pkg/errors"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
-	watchtools "k8s.io/client-go/tools/watch"
 	kutil "kmodules.xyz/client-go"
 )
 
@@ -104,97 +97,45 @@ func untilHasKey(
 		ri = dc.Resource(gvr)
 	}
 
-	lw := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			options.FieldSelector = fields.OneTermEqualSelector(kutil.ObjectNameField, name).String()
-			return ri.List(ctx, options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			options.FieldSelector = fields.OneTermEqualSelector(kutil.ObjectNameField, name).String()
-			return ri.Watch(ctx, options)
-		},
+	obj, err := UntilObject(ctx, ri, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector(kutil.ObjectNameField, name).String(),
+	}, hasKey(fn, key, value))
+	if err != nil {
+		return
 	}
-
-	_, err = watchtools.UntilWithSync(ctx,
-		lw,
-		&unstructured.Unstructured{},
-		nil,
-		func(event watch.Event) (bool, error) {
-			switch event.Type {
-			case watch.Deleted:
-				return false, nil
-			case watch.Error:
-				return false, errors.Wrap(err, "error watching")
-			case watch.Added, watch.Modified:
-				m, e2 := meta.Accessor(event.Object)
-				if e2 != nil {
-					return false, e2
-				}
-				var ok bool
-				if out, ok = fn(m)[key]; ok && (value == nil || *value == out) {
-					return true, nil
-				}
-				return false, nil // continue
-			default:
-				return false, fmt.Errorf("unexpected event type: %v", event.Type)
-			}
-		},
-	)
+	out = fn(obj)[key]
 	return
 }
 
+// DetectWorkload returns the top level workload of the given object and its resource.
+// Use WorkloadDetector to get the full lineage or to register custom resolvers.
 func DetectWorkload(ctx context.Context, config *rest.Config, resource schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, schema.GroupVersionResource, error) {
-	kc := kubernetes.NewForConfigOrDie(config)
 	dc, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, resource, err
 	}
-
-	var ri dynamic.ResourceInterface
-	if namespace != "" {
-		ri = dc.Resource(resource).Namespace(namespace)
-	} else {
-		ri = dc.Resource(resource)
-	}
-
-	obj, err := ri.Get(ctx, name, metav1.GetOptions{})
+	d, err := NewWorkloadDetectorForConfig(config)
 	if err != nil {
 		return nil, resource, err
 	}
-	return findWorkload(ctx, kc, dc, resource, obj)
-}
-
-func findWorkload(ctx context.Context, kc kubernetes.Interface, dc dynamic.Interface, resource schema.GroupVersionResource, obj *unstructured.Unstructured) (*unstructured.Unstructured, schema.GroupVersionResource, error) {
-	m, err := meta.Accessor(obj)
+	lineage, err := d.DetectLineage(ctx, resource, namespace, name)
 	if err != nil {
 		return nil, resource, err
 	}
-	for _, ref := range m.GetOwnerReferences() {
-		if ref.Controller != nil && *ref.Controller {
-			gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
-			ar, err := discovery_util.APIResourceForGVK(kc.Discovery(), gvk)
-			if err != nil {
-				return nil, schema.GroupVersionResource{}, err
-			}
-			gvr := schema.GroupVersionResource{
-				Group:    ar.Group,
-				Version:  ar.Version,
-				Resource: ar.Name,
-			}
-			var ri dynamic.ResourceInterface
-			if ar.Namespaced {
-				ri = dc.Resource(gvr).Namespace(m.GetNamespace())
-			} else {
-				ri = dc.Resource(gvr)
-			}
-			parent, err := ri.Get(ctx, ref.Name, metav1.GetOptions{})
-			if err != nil {
-				return nil, schema.GroupVersionResource{}, err
-			}
-			return findWorkload(ctx, kc, dc, gvr, parent)
-		}
+
+	top := lineage.Top()
+	gvr := top.Resource.GroupVersionResource()
+	var ri dynamic.ResourceInterface
+	if top.Ref.Namespace != "" {
+		ri = dc.Resource(gvr).Namespace(top.Ref.Namespace)
+	} else {
+		ri = dc.Resource(gvr)
+	}
+	obj, err := ri.Get(ctx, top.Ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, schema.GroupVersionResource{}, err
 	}
-	return obj, resource, nil
+	return obj, gvr, nil
 }
 
 func RemoveOwnerReferenceForItems(
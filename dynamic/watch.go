@@ -0,0 +1,104 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"fmt"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// ObjectPredicate returns true if the object satisfies a condition.
+type ObjectPredicate func(obj *unstructured.Unstructured) (bool, error)
+
+// UntilObject watches the objects of a resource matching the label and field selectors of opts
+// until one of them satisfies the predicate, and returns that object. Existing objects are
+// checked first. The watch is resumed with a fresh list if it expires (410 Gone), so it runs
+// until the context is cancelled.
+func UntilObject(ctx context.Context, ri dynamic.ResourceInterface, opts metav1.ListOptions, predicate ObjectPredicate) (*unstructured.Unstructured, error) {
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = opts.LabelSelector
+			options.FieldSelector = opts.FieldSelector
+			return ri.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = opts.LabelSelector
+			options.FieldSelector = opts.FieldSelector
+			return ri.Watch(ctx, options)
+		},
+	}
+
+	var out *unstructured.Unstructured
+	_, err := watchtools.UntilWithSync(ctx,
+		lw,
+		&unstructured.Unstructured{},
+		nil,
+		func(event watch.Event) (bool, error) {
+			switch event.Type {
+			case watch.Deleted:
+				return false, nil
+			case watch.Error:
+				return false, kerr.FromObject(event.Object)
+			case watch.Added, watch.Modified:
+				u, ok := event.Object.(*unstructured.Unstructured)
+				if !ok {
+					return false, fmt.Errorf("unexpected object type: %T", event.Object)
+				}
+				matched, err := predicate(u)
+				if err != nil || !matched {
+					return false, err
+				}
+				out = u
+				return true, nil
+			default:
+				return false, fmt.Errorf("unexpected event type: %v", event.Type)
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HasLabel returns an ObjectPredicate that checks that the object has the label key. If value
+// is not nil, the label must also have that value.
+func HasLabel(key string, value *string) ObjectPredicate {
+	return hasKey(func(obj metav1.Object) map[string]string { return obj.GetLabels() }, key, value)
+}
+
+// HasAnnotation returns an ObjectPredicate that checks that the object has the annotation key.
+// If value is not nil, the annotation must also have that value.
+func HasAnnotation(key string, value *string) ObjectPredicate {
+	return hasKey(func(obj metav1.Object) map[string]string { return obj.GetAnnotations() }, key, value)
+}
+
+func hasKey(fn func(metav1.Object) map[string]string, key string, value *string) ObjectPredicate {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		v, ok := fn(obj)[key]
+		return ok && (value == nil || *value == v), nil
+	}
+}
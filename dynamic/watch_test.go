@@ -0,0 +1,83 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newConfigMap(name string, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace("default")
+	u.SetName(name)
+	u.SetLabels(labels)
+	return u
+}
+
+func TestUntilObject(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ConfigMapList"},
+		newConfigMap("a", map[string]string{"app": "demo"}),
+		newConfigMap("b", map[string]string{"app": "other"}),
+	)
+	ri := dc.Resource(gvr).Namespace("default")
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		obj := newConfigMap("c", map[string]string{"app": "demo", "phase": "ready"})
+		if _, err := ri.Create(context.TODO(), obj, metav1.CreateOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	ready := "ready"
+	obj, err := UntilObject(ctx, ri, metav1.ListOptions{LabelSelector: "app=demo"}, HasLabel("phase", &ready))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetName() != "c" {
+		t.Errorf("expected object c, found %s", obj.GetName())
+	}
+}
+
+func TestUntilObject_Cancelled(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ConfigMapList"},
+		newConfigMap("a", nil),
+	)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	_, err := UntilObject(ctx, dc.Resource(gvr).Namespace("default"), metav1.ListOptions{}, HasAnnotation("missing", nil))
+	if err == nil {
+		t.Fatal("expected error on context cancellation")
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"fmt"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+	discovery_util "kmodules.xyz/client-go/discovery"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+// maxLineageDepth guards against owner reference cycles.
+const maxLineageDepth = 16
+
+// WorkloadParent identifies the parent of an object in a workload hierarchy.
+type WorkloadParent struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Edge is the label of the edge from the parent to the child.
+	Edge kmapi.EdgeLabel
+}
+
+// WorkloadResolver finds the parent of an object in a workload hierarchy when it
+// can not be detected from the controller owner reference of the object. It returns
+// nil if the object does not have a parent known to the resolver.
+type WorkloadResolver interface {
+	ResolveParent(ctx context.Context, obj *metav1.PartialObjectMetadata) (*WorkloadParent, error)
+}
+
+// WorkloadResolverFunc is an adapter to use an ordinary function as a WorkloadResolver.
+type WorkloadResolverFunc func(ctx context.Context, obj *metav1.PartialObjectMetadata) (*WorkloadParent, error)
+
+func (f WorkloadResolverFunc) ResolveParent(ctx context.Context, obj *metav1.PartialObjectMetadata) (*WorkloadParent, error) {
+	return f(ctx, obj)
+}
+
+// NewLabelResolver returns a WorkloadResolver that finds the parent of type gvk whose name
+// is stored in the given label of the object, eg, app.kubernetes.io/instance .
+// The parent is expected to be in the same namespace as the object.
+func NewLabelResolver(gvk schema.GroupVersionKind, nameLabel string, edge kmapi.EdgeLabel) WorkloadResolver {
+	return WorkloadResolverFunc(func(_ context.Context, obj *metav1.PartialObjectMetadata) (*WorkloadParent, error) {
+		name, ok := obj.GetLabels()[nameLabel]
+		if !ok || name == "" {
+			return nil, nil
+		}
+		return &WorkloadParent{
+			GroupVersionKind: gvk,
+			Namespace:        obj.GetNamespace(),
+			Name:             name,
+			Edge:             edge,
+		}, nil
+	})
+}
+
+// WorkloadLineage is the chain of objects from the top level workload to an object.
+type WorkloadLineage struct {
+	// Chain lists the objects starting from the top level workload.
+	Chain []kmapi.ObjectInfo
+	// Edges[i] is the label of the edge from Chain[i] to Chain[i+1].
+	Edges []kmapi.EdgeLabel
+}
+
+// Top returns the top level workload.
+func (l *WorkloadLineage) Top() kmapi.ObjectInfo {
+	return l.Chain[0]
+}
+
+// WorkloadDetector walks the controller owner references of an object, eg,
+// Pod -> ReplicaSet -> Deployment or Pod -> Job -> CronJob, to find the top level
+// workload. Only object metadata is read, so arbitrary custom resources like Argo Rollouts,
+// KubeDB databases or OpenKruise CloneSets are supported without extra configuration.
+// Relationships that are not expressed via owner references can be handled by registering
+// custom resolvers per kind.
+type WorkloadDetector struct {
+	mapper    meta.RESTMapper
+	mc        metadata.Interface
+	resolvers map[schema.GroupKind]WorkloadResolver
+}
+
+// NewWorkloadDetector returns a WorkloadDetector that uses the given rest mapper and metadata client.
+func NewWorkloadDetector(mapper meta.RESTMapper, mc metadata.Interface) *WorkloadDetector {
+	return &WorkloadDetector{
+		mapper:    mapper,
+		mc:        mc,
+		resolvers: map[schema.GroupKind]WorkloadResolver{},
+	}
+}
+
+// NewWorkloadDetectorForConfig returns a WorkloadDetector for the given rest config.
+func NewWorkloadDetectorForConfig(config *rest.Config) (*WorkloadDetector, error) {
+	disc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mc, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewWorkloadDetector(discovery_util.NewRestMapper(disc), mc), nil
+}
+
+// Register sets the resolver used to find the parent of objects of the given kind.
+// Resolvers are consulted before the controller owner reference.
+func (d *WorkloadDetector) Register(gk schema.GroupKind, r WorkloadResolver) *WorkloadDetector {
+	d.resolvers[gk] = r
+	return d
+}
+
+// DetectLineage returns the chain of objects from the top level workload to the given object.
+func (d *WorkloadDetector) DetectLineage(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*WorkloadLineage, error) {
+	gvk, err := d.mapper.KindFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+
+	var lineage WorkloadLineage
+	var edge kmapi.EdgeLabel
+	for depth := 0; ; depth++ {
+		if depth == maxLineageDepth {
+			return nil, fmt.Errorf("failed to detect workload of %s %s/%s: lineage is deeper than %d", gvr.String(), namespace, name, maxLineageDepth)
+		}
+
+		mapping, err := d.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, err
+		}
+		var ri metadata.ResourceInterface
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			ri = d.mc.Resource(mapping.Resource).Namespace(namespace)
+		} else {
+			ri = d.mc.Resource(mapping.Resource)
+			namespace = ""
+		}
+		obj, err := ri.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if depth > 0 && kerr.IsNotFound(err) {
+				break // parent might be already deleted
+			}
+			return nil, err
+		}
+
+		if depth > 0 {
+			lineage.Edges = append(lineage.Edges, edge)
+		}
+		lineage.Chain = append(lineage.Chain, kmapi.ObjectInfo{
+			Resource: *kmapi.NewResourceID(mapping),
+			Ref: kmapi.ObjectReference{
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+			},
+		})
+
+		parent, err := d.resolveParent(ctx, gvk.GroupKind(), obj)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+		gvk, namespace, name, edge = parent.GroupVersionKind, parent.Namespace, parent.Name, parent.Edge
+	}
+
+	// reverse so that the top level workload comes first
+	for i, j := 0, len(lineage.Chain)-1; i < j; i, j = i+1, j-1 {
+		lineage.Chain[i], lineage.Chain[j] = lineage.Chain[j], lineage.Chain[i]
+	}
+	for i, j := 0, len(lineage.Edges)-1; i < j; i, j = i+1, j-1 {
+		lineage.Edges[i], lineage.Edges[j] = lineage.Edges[j], lineage.Edges[i]
+	}
+	return &lineage, nil
+}
+
+func (d *WorkloadDetector) resolveParent(ctx context.Context, gk schema.GroupKind, obj *metav1.PartialObjectMetadata) (*WorkloadParent, error) {
+	if r, ok := d.resolvers[gk]; ok {
+		parent, err := r.ResolveParent(ctx, obj)
+		if err != nil || parent != nil {
+			return parent, err
+		}
+	}
+
+	ref := metav1.GetControllerOfNoCopy(obj)
+	if ref == nil {
+		return nil, nil
+	}
+	return &WorkloadParent{
+		GroupVersionKind: schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind),
+		Namespace:        obj.GetNamespace(),
+		Name:             ref.Name,
+		Edge:             kmapi.EdgeLabelOffshoot,
+	}, nil
+}
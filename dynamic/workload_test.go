@@ -0,0 +1,115 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kmapi "kmodules.xyz/client-go/api/v1"
+
+	"gomodules.xyz/pointer"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+var (
+	podGVK        = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	replicaSetGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}
+	rolloutGVK    = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	databaseGVK   = schema.GroupVersionKind{Group: "kubedb.com", Version: "v1", Kind: "MongoDB"}
+)
+
+func newPartialObject(gvk schema.GroupVersionKind, name string, owner *schema.GroupVersionKind, ownerName string) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	if owner != nil {
+		obj.SetOwnerReferences([]metav1.OwnerReference{
+			{
+				APIVersion: owner.GroupVersion().String(),
+				Kind:       owner.Kind,
+				Name:       ownerName,
+				Controller: pointer.TrueP(),
+			},
+		})
+	}
+	return obj
+}
+
+func newTestDetector(objects ...*metav1.PartialObjectMetadata) *WorkloadDetector {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range []schema.GroupVersionKind{podGVK, replicaSetGVK, rolloutGVK, databaseGVK} {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	scheme := metadatafake.NewTestScheme()
+	_ = metav1.AddMetaToScheme(scheme)
+	mc := metadatafake.NewSimpleMetadataClient(scheme)
+	for _, obj := range objects {
+		gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+		if err := mc.Tracker().Create(gvr, obj, obj.GetNamespace()); err != nil {
+			panic(err)
+		}
+	}
+	return NewWorkloadDetector(mapper, mc)
+}
+
+func TestWorkloadDetector_DetectLineage(t *testing.T) {
+	d := newTestDetector(
+		newPartialObject(podGVK, "demo-abc-xyz", &replicaSetGVK, "demo-abc"),
+		newPartialObject(replicaSetGVK, "demo-abc", &rolloutGVK, "demo"),
+		newPartialObject(rolloutGVK, "demo", nil, ""),
+	)
+
+	lineage, err := d.DetectLineage(context.TODO(), schema.GroupVersionResource{Version: "v1", Resource: "pods"}, "default", "demo-abc-xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, o := range lineage.Chain {
+		names = append(names, o.Resource.Kind+"/"+o.Ref.Name)
+	}
+	if expected := []string{"Rollout/demo", "ReplicaSet/demo-abc", "Pod/demo-abc-xyz"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected chain %v, found %v", expected, names)
+	}
+	if expected := []kmapi.EdgeLabel{kmapi.EdgeLabelOffshoot, kmapi.EdgeLabelOffshoot}; !reflect.DeepEqual(lineage.Edges, expected) {
+		t.Errorf("expected edges %v, found %v", expected, lineage.Edges)
+	}
+}
+
+func TestWorkloadDetector_Resolver(t *testing.T) {
+	pod := newPartialObject(podGVK, "mg-0", nil, "")
+	pod.SetLabels(map[string]string{"app.kubernetes.io/instance": "mg"})
+	d := newTestDetector(pod, newPartialObject(databaseGVK, "mg", nil, ""))
+	d.Register(podGVK.GroupKind(), NewLabelResolver(databaseGVK, "app.kubernetes.io/instance", kmapi.EdgeLabelOffshoot))
+
+	lineage, err := d.DetectLineage(context.TODO(), schema.GroupVersionResource{Version: "v1", Resource: "pods"}, "default", "mg-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top := lineage.Top(); top.Resource.Kind != "MongoDB" || top.Ref.Name != "mg" {
+		t.Errorf("unexpected top level workload %+v", top)
+	}
+	if len(lineage.Chain) != 2 || len(lineage.Edges) != 1 {
+		t.Errorf("unexpected lineage %+v", lineage)
+	}
+}
@@ -52,13 +52,25 @@ type ItemList struct {
 }
 
 type BackupManager struct {
-	cluster  string
-	config   *rest.Config
-	mapper   meta.RESTMapper
-	sanitize bool
+	cluster string
+	config  *rest.Config
+	mapper  meta.RESTMapper
+	profile *SanitizeProfile
 }
 
+// NewBackupManager returns a BackupManager. If sanitize is true, objects are
+// cleaned up using the DefaultSanitizeProfile.
 func NewBackupManager(cluster string, config *rest.Config, sanitize bool) BackupManager {
+	var profile *SanitizeProfile
+	if sanitize {
+		profile = DefaultSanitizeProfile()
+	}
+	return NewBackupManagerWithProfile(cluster, config, profile)
+}
+
+// NewBackupManagerWithProfile returns a BackupManager that cleans up objects
+// using the given profile. A nil profile exports objects as is.
+func NewBackupManagerWithProfile(cluster string, config *rest.Config, profile *SanitizeProfile) BackupManager {
 	hc, err := rest.HTTPClientFor(config)
 	if err != nil {
 		panic(err)
@@ -68,10 +80,10 @@ func NewBackupManager(cluster string, config *rest.Config, sanitize bool) Backup
 		panic(err)
 	}
 	return BackupManager{
-		cluster:  cluster,
-		config:   config,
-		mapper:   mapper,
-		sanitize: sanitize,
+		cluster: cluster,
+		config:  config,
+		mapper:  mapper,
+		profile: profile,
 	}
 }
 
@@ -206,35 +218,11 @@ func (mgr BackupManager) Backup(process processorFunc) error {
 				item["apiVersion"] = list.GroupVersion
 				item["kind"] = r.Kind
 
-				md, ok := item["metadata"]
-				if ok {
+				if _, ok := item["metadata"]; ok {
 					path = getPathFromSelfLink(mgr.mapper, item)
-					if mgr.sanitize {
-						cleanUpObjectMeta(md)
-					}
 				}
-				if mgr.sanitize {
-					if spec, ok := item["spec"].(map[string]interface{}); ok {
-						switch r.Kind {
-						case "Pod":
-							item["spec"], err = cleanUpPodSpec(spec)
-							if err != nil {
-								return err
-							}
-						case "StatefulSet", "Deployment", "ReplicaSet", "DaemonSet", "ReplicationController", "Job":
-							template, ok := spec["template"].(map[string]interface{})
-							if ok {
-								podSpec, ok := template["spec"].(map[string]interface{})
-								if ok {
-									template["spec"], err = cleanUpPodSpec(podSpec)
-									if err != nil {
-										return err
-									}
-								}
-							}
-						}
-					}
-					delete(item, "status")
+				if mgr.profile != nil {
+					mgr.profile.Sanitize(gv.WithKind(r.Kind), item)
 				}
 				data, err := yaml.Marshal(item)
 				if err != nil {
@@ -250,70 +238,6 @@ func (mgr BackupManager) Backup(process processorFunc) error {
 	return nil
 }
 
-func cleanUpObjectMeta(md interface{}) {
-	meta, ok := md.(map[string]interface{})
-	if !ok {
-		return
-	}
-	delete(meta, "creationTimestamp")
-	delete(meta, "resourceVersion")
-	delete(meta, "uid")
-	delete(meta, "generateName")
-	delete(meta, "generation")
-	annotation, ok := meta["annotations"]
-	if !ok {
-		return
-	}
-	annotations, ok := annotation.(map[string]string)
-	if !ok {
-		return
-	}
-	cleanUpDecorators(annotations)
-}
-
-func cleanUpDecorators(m map[string]string) {
-	delete(m, "controller-uid")
-	delete(m, "deployment.kubernetes.io/desired-replicas")
-	delete(m, "deployment.kubernetes.io/max-replicas")
-	delete(m, "deployment.kubernetes.io/revision")
-	delete(m, "pod-template-hash")
-	delete(m, "pv.kubernetes.io/bind-completed")
-	delete(m, "pv.kubernetes.io/bound-by-controller")
-}
-
-func cleanUpPodSpec(in map[string]interface{}) (map[string]interface{}, error) {
-	b, err := yaml.Marshal(in)
-	if err != nil {
-		return nil, err
-	}
-	spec := &core.PodSpec{}
-	err = yaml.Unmarshal(b, spec)
-	if err != nil {
-		return in, nil // Not a podSpec
-	}
-	spec.DNSPolicy = core.DNSPolicy("")
-	spec.NodeName = ""
-	if spec.ServiceAccountName == "default" {
-		spec.ServiceAccountName = ""
-	}
-	spec.TerminationGracePeriodSeconds = nil
-	for i, c := range spec.Containers {
-		c.TerminationMessagePath = ""
-		spec.Containers[i] = c
-	}
-	for i, c := range spec.InitContainers {
-		c.TerminationMessagePath = ""
-		spec.InitContainers[i] = c
-	}
-	b, err = yaml.Marshal(spec)
-	if err != nil {
-		return nil, err
-	}
-	var out map[string]interface{}
-	err = yaml.Unmarshal(b, &out)
-	return out, err
-}
-
 func getPathFromSelfLink(mapper meta.RESTMapper, obj map[string]interface{}) string {
 	u := unstructured.Unstructured{Object: obj}
 	gvk := u.GetObjectKind().GroupVersionKind()
@@ -0,0 +1,282 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// SanitizeRule describes the cleanups applied to an exported object so that
+// it can be reapplied in a different cluster.
+//
+// Field paths are dot separated field names, eg, "metadata.uid". A field name
+// with a "[*]" suffix applies the rest of the path to every item of that list,
+// eg, "spec.containers[*].terminationMessagePath".
+type SanitizeRule struct {
+	// RemoveFields lists the field paths removed from the object.
+	RemoveFields []string `json:"removeFields,omitempty"`
+	// RemoveFieldsWithValue maps a field path to a string value. The field
+	// is removed only if it is set to that value.
+	RemoveFieldsWithValue map[string]string `json:"removeFieldsWithValue,omitempty"`
+	// StripAnnotations removes annotations whose key matches any of these keys.
+	StripAnnotations []string `json:"stripAnnotations,omitempty"`
+	// StripAnnotationPrefixes removes annotations whose key starts with any of these prefixes.
+	StripAnnotationPrefixes []string `json:"stripAnnotationPrefixes,omitempty"`
+	// StripLabelPrefixes removes labels whose key starts with any of these prefixes.
+	StripLabelPrefixes []string `json:"stripLabelPrefixes,omitempty"`
+	// KeepStatus retains the status of the object. If unset, the value
+	// from the enclosing rule is used and status is dropped by default.
+	KeepStatus *bool `json:"keepStatus,omitempty"`
+}
+
+// KindRule is a SanitizeRule applied to objects of a given kind.
+// An empty Version matches every version of the group kind.
+type KindRule struct {
+	Group        string `json:"group,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Kind         string `json:"kind"`
+	SanitizeRule `json:",inline"`
+}
+
+func (r KindRule) Matches(gvk schema.GroupVersionKind) bool {
+	return r.Group == gvk.Group &&
+		r.Kind == gvk.Kind &&
+		(r.Version == "" || r.Version == gvk.Version)
+}
+
+// SanitizeProfile is a declarative set of rules used to clean up objects
+// before they are written to a backup.
+type SanitizeProfile struct {
+	// Default rule is applied to every object.
+	Default SanitizeRule `json:"default"`
+	// Kinds lists additional rules applied on top of the Default rule
+	// for matching objects.
+	Kinds []KindRule `json:"kinds,omitempty"`
+}
+
+var podSpecKinds = []schema.GroupKind{
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "", Kind: "ReplicationController"},
+	{Group: "batch", Kind: "Job"},
+}
+
+// DefaultSanitizeProfile returns the profile used when a BackupManager is
+// created with sanitize enabled.
+func DefaultSanitizeProfile() *SanitizeProfile {
+	p := &SanitizeProfile{
+		Default: SanitizeRule{
+			RemoveFields: []string{
+				"metadata.creationTimestamp",
+				"metadata.resourceVersion",
+				"metadata.uid",
+				"metadata.generateName",
+				"metadata.generation",
+			},
+			StripAnnotations: []string{
+				"controller-uid",
+				"deployment.kubernetes.io/desired-replicas",
+				"deployment.kubernetes.io/max-replicas",
+				"deployment.kubernetes.io/revision",
+				"pod-template-hash",
+				"pv.kubernetes.io/bind-completed",
+				"pv.kubernetes.io/bound-by-controller",
+			},
+		},
+		Kinds: []KindRule{
+			{
+				Group:        core.GroupName,
+				Kind:         "Pod",
+				SanitizeRule: podSpecRule("spec"),
+			},
+		},
+	}
+	for _, gk := range podSpecKinds {
+		p.Kinds = append(p.Kinds, KindRule{
+			Group:        gk.Group,
+			Kind:         gk.Kind,
+			SanitizeRule: podSpecRule("spec.template.spec"),
+		})
+	}
+	return p
+}
+
+func podSpecRule(prefix string) SanitizeRule {
+	return SanitizeRule{
+		RemoveFields: []string{
+			prefix + ".dnsPolicy",
+			prefix + ".nodeName",
+			prefix + ".terminationGracePeriodSeconds",
+			prefix + ".containers[*].terminationMessagePath",
+			prefix + ".initContainers[*].terminationMessagePath",
+		},
+		RemoveFieldsWithValue: map[string]string{
+			prefix + ".serviceAccountName": "default",
+		},
+	}
+}
+
+// LoadSanitizeProfile parses a SanitizeProfile from json or yaml data.
+func LoadSanitizeProfile(data []byte) (*SanitizeProfile, error) {
+	var p SanitizeProfile
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RuleFor returns the effective rule for the given kind by merging the
+// matching kind rules into the Default rule.
+func (p *SanitizeProfile) RuleFor(gvk schema.GroupVersionKind) SanitizeRule {
+	out := SanitizeRule{
+		RemoveFields:            append([]string(nil), p.Default.RemoveFields...),
+		RemoveFieldsWithValue:   map[string]string{},
+		StripAnnotations:        append([]string(nil), p.Default.StripAnnotations...),
+		StripAnnotationPrefixes: append([]string(nil), p.Default.StripAnnotationPrefixes...),
+		StripLabelPrefixes:      append([]string(nil), p.Default.StripLabelPrefixes...),
+		KeepStatus:              p.Default.KeepStatus,
+	}
+	for k, v := range p.Default.RemoveFieldsWithValue {
+		out.RemoveFieldsWithValue[k] = v
+	}
+	for _, r := range p.Kinds {
+		if !r.Matches(gvk) {
+			continue
+		}
+		out.RemoveFields = append(out.RemoveFields, r.RemoveFields...)
+		for k, v := range r.RemoveFieldsWithValue {
+			out.RemoveFieldsWithValue[k] = v
+		}
+		out.StripAnnotations = append(out.StripAnnotations, r.StripAnnotations...)
+		out.StripAnnotationPrefixes = append(out.StripAnnotationPrefixes, r.StripAnnotationPrefixes...)
+		out.StripLabelPrefixes = append(out.StripLabelPrefixes, r.StripLabelPrefixes...)
+		if r.KeepStatus != nil {
+			out.KeepStatus = r.KeepStatus
+		}
+	}
+	return out
+}
+
+// Sanitize cleans up the object in place using the rule for its kind.
+func (p *SanitizeProfile) Sanitize(gvk schema.GroupVersionKind, obj map[string]interface{}) {
+	p.RuleFor(gvk).Apply(obj)
+}
+
+// Apply cleans up the object in place.
+func (r SanitizeRule) Apply(obj map[string]interface{}) {
+	for _, path := range r.RemoveFields {
+		removeField(obj, parseFieldPath(path), nil)
+	}
+	for path, value := range r.RemoveFieldsWithValue {
+		v := value
+		removeField(obj, parseFieldPath(path), &v)
+	}
+	if md, ok := obj["metadata"].(map[string]interface{}); ok {
+		stripKeys(md, "annotations", r.StripAnnotations, r.StripAnnotationPrefixes)
+		stripKeys(md, "labels", nil, r.StripLabelPrefixes)
+	}
+	if r.KeepStatus == nil || !*r.KeepStatus {
+		delete(obj, "status")
+	}
+}
+
+// stripKeys removes the entries of md[field] whose key is in keys or starts
+// with any of the prefixes.
+func stripKeys(md map[string]interface{}, field string, keys, prefixes []string) {
+	if len(keys) == 0 && len(prefixes) == 0 {
+		return
+	}
+	m, ok := md[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, k := range keys {
+		delete(m, k)
+	}
+	for k := range m {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				delete(m, k)
+				break
+			}
+		}
+	}
+	if len(m) == 0 {
+		delete(md, field)
+	}
+}
+
+type pathElement struct {
+	name string
+	each bool
+}
+
+func parseFieldPath(path string) []pathElement {
+	parts := strings.Split(path, ".")
+	out := make([]pathElement, 0, len(parts))
+	for _, part := range parts {
+		if name, ok := strings.CutSuffix(part, "[*]"); ok {
+			out = append(out, pathElement{name: name, each: true})
+		} else {
+			out = append(out, pathElement{name: part})
+		}
+	}
+	return out
+}
+
+// removeField removes the field at path. If value is not nil, the field is
+// only removed when it is a string equal to *value.
+func removeField(obj map[string]interface{}, path []pathElement, value *string) {
+	if len(path) == 0 {
+		return
+	}
+	e := path[0]
+	v, ok := obj[e.name]
+	if !ok {
+		return
+	}
+	if len(path) == 1 && !e.each {
+		if value != nil {
+			if s, ok := v.(string); !ok || s != *value {
+				return
+			}
+		}
+		delete(obj, e.name)
+		return
+	}
+	if !e.each {
+		if m, ok := v.(map[string]interface{}); ok {
+			removeField(m, path[1:], value)
+		}
+		return
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			removeField(m, path[1:], value)
+		}
+	}
+}
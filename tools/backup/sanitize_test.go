@@ -0,0 +1,126 @@
+/*
+Copyright AppsCode Inc. and Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: demo
+  namespace: default
+  uid: 0b5b7a8e-7c3c-4f1c-9b5a-3d1f0f3c0c7e
+  resourceVersion: "1234"
+  generation: 2
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  annotations:
+    deployment.kubernetes.io/revision: "2"
+    deployment.kubernetes.io/revision-history: "1,2"
+    example.com/owner: team-a
+  labels:
+    app: demo
+spec:
+  template:
+    spec:
+      dnsPolicy: ClusterFirst
+      serviceAccountName: default
+      terminationGracePeriodSeconds: 30
+      containers:
+      - name: app
+        image: nginx
+        terminationMessagePath: /dev/termination-log
+status:
+  replicas: 1
+`
+
+const sanitizedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: demo
+  namespace: default
+  annotations:
+    deployment.kubernetes.io/revision-history: "1,2"
+    example.com/owner: team-a
+  labels:
+    app: demo
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx
+`
+
+func TestDefaultSanitizeProfile(t *testing.T) {
+	var obj, expected map[string]interface{}
+	if err := yaml.Unmarshal([]byte(deployment), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(sanitizedDeployment), &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	DefaultSanitizeProfile().Sanitize(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, obj)
+	if !reflect.DeepEqual(obj, expected) {
+		got, _ := yaml.Marshal(obj)
+		t.Errorf("unexpected sanitized object:\n%s", got)
+	}
+}
+
+func TestSanitizeProfile_KindRule(t *testing.T) {
+	keep := true
+	p := &SanitizeProfile{
+		Default: SanitizeRule{
+			StripLabelPrefixes: []string{"example.com/"},
+		},
+		Kinds: []KindRule{
+			{
+				Group: "apps",
+				Kind:  "Deployment",
+				SanitizeRule: SanitizeRule{
+					RemoveFields: []string{"spec.template.spec.containers[*].image"},
+					KeepStatus:   &keep,
+				},
+			},
+		},
+	}
+
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(deployment), &obj); err != nil {
+		t.Fatal(err)
+	}
+	obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{})["example.com/tier"] = "web"
+
+	p.Sanitize(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, obj)
+	if _, ok := obj["status"]; !ok {
+		t.Errorf("expected status to be kept")
+	}
+	labels := obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+	if !reflect.DeepEqual(labels, map[string]interface{}{"app": "demo"}) {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	c := obj["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0]
+	if _, ok := c.(map[string]interface{})["image"]; ok {
+		t.Errorf("expected container image to be removed")
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package clientcmd
 
 import (
+	discovery_util "kmodules.xyz/client-go/discovery"
+
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -30,6 +32,7 @@ import (
 
 type restClientGetter struct {
 	config *clientcmdapi.Config
+	cache  *discovery_util.Cache
 }
 
 var _ genericclioptions.RESTClientGetter = restClientGetter{}
@@ -44,6 +47,10 @@ func (r restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterfac
 		return nil, err
 	}
 
+	if r.cache != nil {
+		return r.cache.DiscoveryClientForConfig(config)
+	}
+
 	// Don't use disk based cache as that makes it unsafe for multi-tenant backend servers
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
@@ -57,6 +64,9 @@ func (r restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
 	if err != nil {
 		return nil, err
 	}
+	if r.cache != nil {
+		return r.cache.RESTMapperForConfig(config)
+	}
 	hc, err := rest.HTTPClientFor(config)
 	if err != nil {
 		return nil, err
@@ -69,7 +79,14 @@ func (r restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
 }
 
 func NewClientGetter(config *clientcmdapi.Config) genericclioptions.RESTClientGetter {
-	return &restClientGetter{config}
+	return &restClientGetter{config: config}
+}
+
+// NewClientGetterWithCache returns a RESTClientGetter that shares discovery info using the
+// given cache. The cache is keyed per cluster and user, so it is safe to use by multi-tenant
+// servers.
+func NewClientGetterWithCache(config *clientcmdapi.Config, cache *discovery_util.Cache) genericclioptions.RESTClientGetter {
+	return &restClientGetter{config: config, cache: cache}
 }
 
 func NewClientGetterFromFlags(fs *pflag.FlagSet) genericclioptions.RESTClientGetter {
@@ -17,10 +17,15 @@ limitations under the License.
 package clientcmd
 
 import (
+	"context"
+
+	cu "kmodules.xyz/client-go/client"
+
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // https://github.com/kubernetes/client-go/issues/711#issuecomment-730112049
@@ -79,3 +84,14 @@ func BuildKubeConfigBytes(cfg *rest.Config, namespace string) ([]byte, error) {
 	}
 	return runtime.Encode(clientcmdlatest.Codec, clientConfig)
 }
+
+// BuildServiceAccountKubeConfig returns a kubeconfig for cfg's cluster that authenticates as the given
+// ServiceAccount using a token issued by tp. The namespace of the ServiceAccount is used as the
+// default namespace.
+func BuildServiceAccountKubeConfig(ctx context.Context, cfg *rest.Config, tp *cu.TokenProvider, sa client.ObjectKey) ([]byte, error) {
+	saConfig, err := tp.RESTConfig(ctx, cfg, sa)
+	if err != nil {
+		return nil, err
+	}
+	return BuildKubeConfigBytes(saConfig, sa.Namespace)
+}